@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/archive"
+	"github.com/JamesTiberiusKirk/fishstox/internal/cacher"
+	"github.com/JamesTiberiusKirk/fishstox/internal/config"
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+const usage = `usage: fishstox <command> [flags]
+
+commands:
+  replay    re-ingest archived prices payloads into the database
+  rollup    rebuild the price rollup tables: rollup rebuild [flags]
+  retention downsample and remove raw prices past RETENTION_RAW
+  migrate   manage the schema: migrate up|down|status|create [flags]
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	switch os.Args[1] {
+	case "replay":
+		err = replay(ctx, logger, os.Args[2:])
+	case "rollup":
+		err = rollup(ctx, logger, os.Args[2:])
+	case "retention":
+		err = retention(ctx, logger, os.Args[2:])
+	case "migrate":
+		err = migrate(ctx, logger, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		logger.Error("Command failed", "command", os.Args[1], "error", err)
+		os.Exit(1)
+	}
+}
+
+func replay(ctx context.Context, logger *slog.Logger, args []string) error {
+	config := config.GetConfig()
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := fs.String("dir", config.ArchiveDir, "archive directory")
+	since := fs.Duration("since", 0, "only replay payloads fetched within this duration, 0 replays everything")
+	fs.Parse(args)
+
+	if *dir == "" {
+		return fmt.Errorf("no archive directory, set ARCHIVE_DIR or -dir")
+	}
+
+	a, err := archive.New(*dir)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	db, err := db.InitClient(logger,
+		config.DbUser, config.DbPass, config.DbHost, config.DbName,
+		true, time.Now)
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer db.Close()
+
+	// Replayed prices go through the rollups like scraped ones, see
+	// cmd/scraper.
+	switch {
+	case config.DbTimescale:
+		err := db.SetupTimescale(ctx, config.TimescaleCompressAfter, config.TimescaleDropAfter)
+		if err != nil {
+			return fmt.Errorf("failed to set up timescale: %w", err)
+		}
+	case config.DbRollups:
+		db.MaintainRollups()
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	// Replaying never talks to the upstream API.
+	stoxClient := stox.NewClient(nil, "", nil, stox.RetryPolicy{}, nil)
+	c := cacher.NewCacher(logger, db, stoxClient, events.New(logger, db), config)
+
+	report, err := c.Replay(ctx, a, from)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Replay done", "payloads", report.Payloads, "failed", report.Failed,
+		"inserted", report.Inserted, "skipped", report.Skipped)
+	return nil
+}
+
+func rollup(ctx context.Context, logger *slog.Logger, args []string) error {
+	if len(args) < 1 || args[0] != "rebuild" {
+		return fmt.Errorf("unknown rollup command, expected: rollup rebuild")
+	}
+
+	config := config.GetConfig()
+
+	fs := flag.NewFlagSet("rollup rebuild", flag.ExitOnError)
+	ticker := fs.String("ticker", "", "ticker to rebuild, empty rebuilds every ticker")
+	fromRaw := fs.String("from", "", "RFC3339 start of the range, empty starts at the first price")
+	toRaw := fs.String("to", "", "RFC3339 end of the range, empty ends at the last price")
+	fs.Parse(args[1:])
+
+	var from, to time.Time
+	var err error
+	if *fromRaw != "" {
+		if from, err = time.Parse(time.RFC3339, *fromRaw); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *toRaw != "" {
+		if to, err = time.Parse(time.RFC3339, *toRaw); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	db, err := db.InitClient(logger,
+		config.DbUser, config.DbPass, config.DbHost, config.DbName,
+		true, time.Now)
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer db.Close()
+
+	tickers := []string{*ticker}
+	if *ticker == "" {
+		if tickers, err = db.GetTickers(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tickers {
+		first, last, ok, err := db.GetPriceRange(ctx, t)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("No prices to roll up", "ticker", t)
+			continue
+		}
+
+		start, end := first, last.Add(time.Millisecond)
+		if !from.IsZero() {
+			start = from
+		}
+		if !to.IsZero() {
+			end = to
+		}
+
+		days, err := db.RebuildRollups(ctx, t, start, end)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild rollups of %s: %w", t, err)
+		}
+
+		logger.Info("Rebuilt rollups", "ticker", t, "days", days)
+	}
+
+	return nil
+}
+
+func retention(ctx context.Context, logger *slog.Logger, args []string) error {
+	config := config.GetConfig()
+
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report how many rows would be removed per ticker")
+	fs.Parse(args)
+
+	db, err := db.InitClient(logger,
+		config.DbUser, config.DbPass, config.DbHost, config.DbName,
+		true, time.Now)
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer db.Close()
+
+	stoxClient := stox.NewClient(nil, "", nil, stox.RetryPolicy{}, nil)
+	c := cacher.NewCacher(logger, db, stoxClient, events.New(logger, db), config)
+
+	report, err := c.Retention(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("cutoff %s (dry run: %t)\n", report.Cutoff.Format(time.RFC3339), report.DryRun)
+	for _, t := range report.Tickers {
+		fmt.Printf("%-10s raw %d rollup %d\n", t.Ticker, t.RawRows, t.RollupRows)
+	}
+
+	return nil
+}
+
+func migrate(ctx context.Context, logger *slog.Logger, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing migrate command, expected: migrate up|down|status|create")
+	}
+
+	if args[0] == "create" {
+		return createMigration(logger, args[1:])
+	}
+
+	config := config.GetConfig()
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert, for down")
+	fs.Parse(args[1:])
+
+	db, err := db.InitClient(logger,
+		config.DbUser, config.DbPass, config.DbHost, config.DbName,
+		true, time.Now)
+	if err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations %v\n", len(applied), applied)
+	case "down":
+		reverted, err := db.MigrateDown(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migrations %v\n", len(reverted), reverted)
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !status.Initialised {
+			fmt.Println("schema not initialised")
+			return nil
+		}
+
+		fmt.Printf("version %d\n", status.Version)
+		for _, m := range status.Pending() {
+			fmt.Printf("pending %d\n", m.Version)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected: migrate up|down|status|create", args[0])
+	}
+
+	return nil
+}
+
+// createMigration writes an empty migration after the latest one in the
+// migrations directory, to be run from the repository root.
+func createMigration(logger *slog.Logger, args []string) error {
+	fs := flag.NewFlagSet("migrate create", flag.ExitOnError)
+	dir := fs.String("dir", db.MigrationsDir, "migrations directory")
+	fs.Parse(args)
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	// The directory is read rather than the embedded migrations, so ones
+	// created since the binary was built are counted.
+	version := 1
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".sql")
+		if e.IsDir() || !ok {
+			continue
+		}
+
+		v, err := strconv.Atoi(name)
+		if err != nil || v < 1 {
+			return fmt.Errorf("invalid migration file name %s, expected <version>.sql", e.Name())
+		}
+		version = max(version, v+1)
+	}
+
+	name := filepath.Join(*dir, fmt.Sprintf("%d.sql", version))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("-- name: up\n\n-- name: down\n"); err != nil {
+		return fmt.Errorf("failed to write migration: %w", err)
+	}
+
+	logger.Info("Created migration", "file", name, "version", version,
+		"note", "add new tables to schema.sql too")
+	return nil
+}
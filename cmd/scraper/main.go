@@ -4,11 +4,16 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/JamesTiberiusKirk/fishstox/internal/archive"
 	"github.com/JamesTiberiusKirk/fishstox/internal/cacher"
 	"github.com/JamesTiberiusKirk/fishstox/internal/config"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/sources"
 )
 
 func main() {
@@ -21,10 +26,48 @@ func main() {
 	if err != nil {
 		panic("error connecting to db " + err.Error())
 	}
+	db.SetPool(config.DbMaxOpenConns, config.DbMaxIdleConns, config.DbConnMaxLifetime, config.DbConnMaxIdleTime)
+	db.SetQueryTimeout(config.DbQueryTimeout)
+	defer db.Close()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	c := cacher.NewCacher(logger, db)
-	c.Scrape(ctx)
+	if config.DbMigrate {
+		if _, err := db.MigrateUp(ctx); err != nil {
+			panic("error migrating db " + err.Error())
+		}
+	}
+
+	// Rollup tables are only maintained when timescale is not doing it.
+	switch {
+	case config.DbTimescale:
+		err := db.SetupTimescale(ctx, config.TimescaleCompressAfter, config.TimescaleDropAfter)
+		if err != nil {
+			panic("error setting up timescale " + err.Error())
+		}
+	case config.DbRollups:
+		db.MaintainRollups()
+	}
+
+	source, err := sources.New(config)
+	if err != nil {
+		panic("error creating price source " + err.Error())
+	}
+
+	c := cacher.NewCacher(logger, db, source, events.New(logger, db), config)
+
+	if config.ArchiveDir != "" {
+		a, err := archive.New(config.ArchiveDir)
+		if err != nil {
+			panic("error opening archive " + err.Error())
+		}
+		c.SetArchive(a)
+	}
+
+	if err := c.Scrape(ctx); err != nil {
+		panic("error starting scraper " + err.Error())
+	}
+
+	logger.Info("Shutting down scraper")
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
@@ -8,10 +9,15 @@ import (
 
 	"github.com/JamesTiberiusKirk/fishstox/internal/config"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/live"
 	"github.com/JamesTiberiusKirk/fishstox/internal/middleware"
 	"github.com/JamesTiberiusKirk/fishstox/internal/web/charts/candlestick"
 	"github.com/JamesTiberiusKirk/fishstox/internal/web/charts/simple"
 	"github.com/JamesTiberiusKirk/fishstox/internal/web/index"
+	weblive "github.com/JamesTiberiusKirk/fishstox/internal/web/live"
+	"github.com/JamesTiberiusKirk/fishstox/internal/web/scrapes"
+	"github.com/JamesTiberiusKirk/fishstox/internal/web/tickers"
 	"github.com/rickb777/servefiles/v3"
 )
 
@@ -32,9 +38,31 @@ func main() {
 	if err != nil {
 		panic("error connecting to db " + err.Error())
 	}
+	db.SetPool(config.DbMaxOpenConns, config.DbMaxIdleConns, config.DbConnMaxLifetime, config.DbConnMaxIdleTime)
+	db.SetQueryTimeout(config.DbQueryTimeout)
 
-	// ctx, cancel := context.WithCancel(context.Background())
-	// defer cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if config.DbMigrate {
+		if _, err := db.MigrateUp(ctx); err != nil {
+			panic("error migrating db " + err.Error())
+		}
+	}
+
+	switch {
+	case config.DbTimescale:
+		db.UseTimescale()
+	case config.DbRollups:
+		db.UseRollups()
+	}
+
+	hub := live.NewHub(logger, events.New(logger, db))
+	go func() {
+		if err := hub.Run(ctx); err != nil {
+			logger.Error("live price hub stopped", "error", err)
+		}
+	}()
 
 	// {
 	// 	c := cacher.NewCacher(logger, db)
@@ -46,6 +74,9 @@ func main() {
 		serverMux.Handle("/{$}", index.NewHandler(db))
 		serverMux.Handle("/charts/simple/{tickerQuery}", simple.NewHandler(db))
 		serverMux.Handle("/charts/candlestick/{tickerQuery}", candlestick.NewHandler(db))
+		serverMux.Handle("/scrapes", scrapes.NewHandler(db))
+		serverMux.Handle("/tickers/search", tickers.NewHandler(db))
+		serverMux.Handle("/live/prices/{tickerQuery}", weblive.NewHandler(hub, db))
 		assets := servefiles.NewAssetHandler("./assets/").WithMaxAge(time.Hour)
 		serverMux.Handle("/assets/", http.StripPrefix("/assets/", assets))
 		loggedServer := middleware.Logger(logger, serverMux)
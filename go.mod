@@ -3,18 +3,17 @@ module github.com/JamesTiberiusKirk/fishstox
 go 1.24.2
 
 require (
-	github.com/JamesTiberiusKirk/migrator v1.0.3
 	github.com/Masterminds/squirrel v1.5.4
 	github.com/a-h/templ v0.3.857
 	github.com/alexedwards/scs/v2 v2.8.0
 	github.com/joho/godotenv v1.5.1
+	github.com/knadh/goyesql v2.0.0+incompatible
 	github.com/lib/pq v1.10.9
 	github.com/rickb777/servefiles/v3 v3.9.2
 )
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
-	github.com/knadh/goyesql v2.0.0+incompatible // indirect
 	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
 	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
 	github.com/rickb777/path v1.3.1 // indirect
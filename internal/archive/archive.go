@@ -0,0 +1,175 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Entry is the metadata of a single archived fetch. The body itself is stored
+// once per unique content under its sha256 hash.
+type Entry struct {
+	Hash      string    `json:"hash"`
+	Endpoint  string    `json:"endpoint"`
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	LatencyMs int64     `json:"latencyMs"`
+	Size      int       `json:"size"`
+}
+
+// Archive is a content-addressed store of raw upstream payloads on the
+// filesystem. Bodies live gzipped in objects/<hash[:2]>/<hash>.json.gz and
+// every fetch gets a metadata file in fetches/.
+type Archive struct {
+	dir string
+}
+
+// New opens the archive in dir, creating it if needed.
+func New(dir string) (*Archive, error) {
+	for _, sub := range []string{"objects", "fetches"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create archive dir: %w", err)
+		}
+	}
+
+	return &Archive{dir: dir}, nil
+}
+
+// Store archives a body with its fetch metadata and returns the completed
+// entry. Bodies already in the archive are not written again.
+func (a *Archive) Store(e Entry, body []byte) (Entry, error) {
+	sum := sha256.Sum256(body)
+	e.Hash = hex.EncodeToString(sum[:])
+	e.Size = len(body)
+
+	objPath := a.objectPath(e.Hash)
+	if _, err := os.Stat(objPath); errors.Is(err, fs.ErrNotExist) {
+		if err := a.writeObject(objPath, body); err != nil {
+			return e, err
+		}
+	} else if err != nil {
+		return e, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return e, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+
+	name := strconv.FormatInt(e.FetchedAt.UnixMilli(), 10) + "-" + e.Hash[:12] + ".json"
+	if err := writeFileAtomic(filepath.Join(a.dir, "fetches", name), meta); err != nil {
+		return e, fmt.Errorf("failed to write entry: %w", err)
+	}
+
+	return e, nil
+}
+
+// List returns every archived fetch, oldest first.
+func (a *Archive) List() ([]Entry, error) {
+	files, err := os.ReadDir(filepath.Join(a.dir, "fetches"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(a.dir, "fetches", f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %s: %w", f.Name(), err)
+		}
+
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal entry %s: %w", f.Name(), err)
+		}
+		entries = append(entries, e)
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return a.FetchedAt.Compare(b.FetchedAt)
+	})
+
+	return entries, nil
+}
+
+// Open returns the decompressed body stored under hash.
+func (a *Archive) Open(hash string) ([]byte, error) {
+	f, err := os.Open(a.objectPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	defer zr.Close()
+
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress object: %w", err)
+	}
+
+	return body, nil
+}
+
+func (a *Archive) objectPath(hash string) string {
+	return filepath.Join(a.dir, "objects", hash[:2], hash+".json.gz")
+}
+
+func (a *Archive) writeObject(path string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create object dir: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress object: %w", err)
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes to a temp file and renames it into place so readers
+// never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
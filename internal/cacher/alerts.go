@@ -0,0 +1,49 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// AlertPriceMove is the name of the alert fired when a ticker moves by more
+// than the configured percentage within the alert window.
+const AlertPriceMove = "price_move"
+
+// checkPriceMove fires an AlertPriceMove alert when the newly ingested prices
+// of a single ticker, ordered by timestamp, moved by at least the configured
+// percentage within the alert window ending at the latest of them. Prices
+// older than the window, such as backfilled ones, never fire alerts.
+func (c *Cacher) checkPriceMove(ctx context.Context, prices []models.StockPrice) {
+	if c.config.AlertPriceMove <= 0 || len(prices) == 0 {
+		return
+	}
+
+	window := c.config.AlertWindow.Milliseconds()
+	last := prices[len(prices)-1]
+	if last.Timestamp < time.Now().UnixMilli()-window {
+		return
+	}
+
+	first := last
+	for i := len(prices) - 1; i >= 0 && prices[i].Timestamp >= last.Timestamp-window; i-- {
+		first = prices[i]
+	}
+
+	change, ok := models.PercentChange(first.Value, last.Value)
+	threshold := models.Percent(c.config.AlertPriceMove * 100)
+	if !ok || (change < threshold && change > -threshold) {
+		return
+	}
+
+	c.log.Info("Price move alert", "ticker", last.Ticker, "change", change.String())
+	c.publish(ctx, events.AlertFired{
+		Name:    AlertPriceMove,
+		Ticker:  last.Ticker,
+		Value:   last.Value,
+		Message: fmt.Sprintf("%s moved %s to %s in %s", last.Ticker, change, last.Value, time.Duration(last.Timestamp-first.Timestamp)*time.Millisecond),
+	})
+}
@@ -0,0 +1,92 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/archive"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// SetArchive makes the cacher archive the raw body of every upstream response.
+// It does nothing for sources that don't expose raw payloads.
+func (c *Cacher) SetArchive(a *archive.Archive) {
+	pr, ok := c.source.(payloadReporter)
+	if !ok {
+		c.log.Warn("Price source does not support archiving")
+		return
+	}
+
+	pr.SetPayloadHandler(func(ctx context.Context, p stox.Payload) {
+		_, err := a.Store(archive.Entry{
+			Endpoint:  p.Endpoint,
+			URL:       p.URL,
+			FetchedAt: p.FetchedAt,
+			LatencyMs: p.Latency.Milliseconds(),
+		}, p.Body)
+		if err != nil {
+			c.log.Error("Error archiving payload", "endpoint", p.Endpoint, "error", err)
+		}
+	})
+}
+
+// ReplayReport describes the outcome of a replay.
+type ReplayReport struct {
+	Payloads int
+	Failed   int
+	Inserted int
+	Skipped  int
+}
+
+// Replay re-ingests every archived prices payload fetched since the given time
+// through processPrices, oldest first. Payloads with the same content are only
+// ingested once.
+func (c *Cacher) Replay(ctx context.Context, a *archive.Archive, since time.Time) (ReplayReport, error) {
+	var report ReplayReport
+
+	entries, err := a.List()
+	if err != nil {
+		return report, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.Endpoint != stox.PricesEndpoint || e.FetchedAt.Before(since) || seen[e.Hash] {
+			continue
+		}
+		seen[e.Hash] = true
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		body, err := a.Open(e.Hash)
+		if err != nil {
+			return report, fmt.Errorf("failed to open payload %s: %w", e.Hash, err)
+		}
+
+		data, issues, err := stox.DecodePriceData(body)
+		if err != nil {
+			c.log.Error("Error decoding archived payload", "hash", e.Hash, "error", err)
+			report.Failed++
+			continue
+		}
+		if len(issues) > 0 {
+			c.log.Warn("Archived payload has schema issues", "hash", e.Hash, "issues", len(issues))
+		}
+
+		res, err := c.processPrices(ctx, data)
+		if err != nil {
+			return report, fmt.Errorf("failed to replay payload %s: %w", e.Hash, err)
+		}
+
+		report.Payloads++
+		report.Inserted += res.Inserted
+		report.Skipped += res.Skipped
+		c.log.Info("Replayed payload", "hash", e.Hash, "fetchedAt", e.FetchedAt,
+			"inserted", res.Inserted, "skipped", res.Skipped)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,144 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// BackfillReport describes the outcome of a backfill run.
+type BackfillReport struct {
+	Gaps        int
+	Filled      int
+	Ingested    RunStats
+	Unrecovered []models.PriceGap
+}
+
+// rangeWindows is how far back each price range reaches, shortest first.
+var rangeWindows = []struct {
+	interval stox.PriceInterval
+	window   time.Duration
+}{
+	{stox.PriceIntervalDay, 24 * time.Hour},
+	{stox.PriceIntervalWeek, 7 * 24 * time.Hour},
+	{stox.PriceIntervalMax, 0},
+}
+
+// coveringInterval returns the widest price range any of the gaps needs, so a
+// single fetch of it reaches back to the start of every one of them.
+func coveringInterval(gaps []models.PriceGap, now time.Time) stox.PriceInterval {
+	widest := 0
+	for _, g := range gaps {
+		for i, rw := range rangeWindows {
+			if rw.window == 0 || time.UnixMilli(g.From).After(now.Add(-rw.window)) {
+				widest = max(widest, i)
+				break
+			}
+		}
+	}
+	return rangeWindows[widest].interval
+}
+
+// Backfill finds gaps in the price history within the configured lookback and
+// re-fetches the price range covering all of them. Gaps that are still there
+// afterwards are reported as unrecovered and recorded in the database, so
+// later runs, even after a restart, do not retry them.
+func (c *Cacher) Backfill(ctx context.Context) (BackfillReport, error) {
+	var report BackfillReport
+
+	now := time.Now()
+	from := now.Add(-c.config.BackfillLookback)
+
+	gaps, err := c.findGaps(ctx, from, now)
+	if err != nil {
+		return report, err
+	}
+	report.Gaps = len(gaps)
+
+	if len(gaps) == 0 {
+		return report, nil
+	}
+
+	interval := coveringInterval(gaps, now)
+	c.log.Info("Backfilling gaps", "interval", string(interval))
+
+	start := time.Now()
+	data, err := c.source.GetPriceData(ctx, interval)
+	report.Ingested.UpstreamLatency = time.Since(start)
+	if err != nil {
+		return report, fmt.Errorf("failed to get price data from source: %w", err)
+	}
+
+	res, err := c.processPrices(ctx, data)
+	if err != nil {
+		return report, err
+	}
+	report.Ingested.RowsInserted = res.Inserted
+	report.Ingested.RowsSkipped = res.Skipped
+
+	remaining, err := c.findGaps(ctx, from, now)
+	if err != nil {
+		return report, err
+	}
+
+	if err := c.db.MarkUnrecoverableGaps(ctx, remaining, from); err != nil {
+		return report, fmt.Errorf("failed to record unrecoverable gaps: %w", err)
+	}
+
+	report.Unrecovered = remaining
+	report.Filled = report.Gaps - len(remaining)
+	return report, nil
+}
+
+// findGaps returns the gaps of every ticker, skipping ones within a range
+// already known to be unrecoverable.
+func (c *Cacher) findGaps(ctx context.Context, from, to time.Time) ([]models.PriceGap, error) {
+	tickers, err := c.db.GetTickers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tickers: %w", err)
+	}
+
+	var gaps []models.PriceGap
+	for _, t := range tickers {
+		tickerGaps, err := c.db.FindGaps(ctx, t, from, to, c.config.BackfillMaxGap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find gaps for %s: %w", t, err)
+		}
+		gaps = append(gaps, tickerGaps...)
+	}
+
+	unrecoverable, err := c.db.GetUnrecoverableGaps(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unrecoverable gaps: %w", err)
+	}
+
+	// Whatever is left of an unrecoverable gap after a partial fill is not
+	// retried either.
+	gaps = slices.DeleteFunc(gaps, func(g models.PriceGap) bool {
+		return slices.ContainsFunc(unrecoverable, func(u models.PriceGap) bool {
+			return u.Ticker == g.Ticker && u.From <= g.From && g.To <= u.To
+		})
+	})
+
+	return gaps, nil
+}
+
+func (c *Cacher) backfill(ctx context.Context) (RunStats, error) {
+	report, err := c.Backfill(ctx)
+	if err != nil {
+		return report.Ingested, err
+	}
+
+	for _, g := range report.Unrecovered {
+		c.log.Warn("Could not recover price gap", "ticker", g.Ticker,
+			"from", time.UnixMilli(g.From), "to", time.UnixMilli(g.To))
+	}
+
+	c.log.Info("Backfill done", "gaps", report.Gaps, "filled", report.Filled,
+		"unrecovered", len(report.Unrecovered))
+	return report.Ingested, nil
+}
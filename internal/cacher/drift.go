@@ -0,0 +1,67 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// recordDrift stores a drift event whenever the set of schema issues of an
+// endpoint changes, including to none when drift clears, so the same drift
+// coming back later is recorded again. Drift that persists across scrapes is
+// only logged, so the table holds one event and payload per change in the
+// upstream API.
+func (c *Cacher) recordDrift(ctx context.Context, d stox.Drift) {
+	signature := d.Signature()
+
+	last, err := c.lastDriftSignature(ctx, d.Endpoint)
+	if err != nil {
+		c.log.Error("Error getting last drift signature", "endpoint", d.Endpoint, "error", err)
+	}
+
+	if last == signature {
+		if signature != "" {
+			c.log.Debug("Upstream schema drift persists", "endpoint", d.Endpoint, "issues", len(d.Issues))
+		}
+		return
+	}
+
+	if signature == "" {
+		c.log.Info("Upstream schema drift cleared", "endpoint", d.Endpoint)
+	} else {
+		issues := make([]string, 0, len(d.Issues))
+		for _, i := range d.Issues {
+			issues = append(issues, i.String())
+		}
+		c.log.Warn("Upstream schema drift detected", "endpoint", d.Endpoint, "issues", issues)
+	}
+
+	if err := c.db.AddDriftEvent(ctx, d); err != nil {
+		c.log.Error("Error recording drift event", "endpoint", d.Endpoint, "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.driftSignatures[d.Endpoint] = signature
+	c.mu.Unlock()
+}
+
+func (c *Cacher) lastDriftSignature(ctx context.Context, endpoint string) (string, error) {
+	c.mu.Lock()
+	signature, ok := c.driftSignatures[endpoint]
+	c.mu.Unlock()
+	if ok {
+		return signature, nil
+	}
+
+	signature, err := c.db.GetLastDriftSignature(ctx, endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to get last drift signature: %w", err)
+	}
+
+	c.mu.Lock()
+	c.driftSignatures[endpoint] = signature
+	c.mu.Unlock()
+	return signature, nil
+}
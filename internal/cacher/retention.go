@@ -0,0 +1,45 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+)
+
+// Retention downsamples and removes raw prices older than the configured
+// retention. With dryRun nothing is removed and the report holds what would
+// be.
+func (c *Cacher) Retention(ctx context.Context, dryRun bool) (db.RetentionReport, error) {
+	if c.config.DbTimescale {
+		return db.RetentionReport{}, fmt.Errorf("retention is handled by timescale, set TIMESCALE_DROP_AFTER instead")
+	}
+	if !c.config.DbRollups {
+		return db.RetentionReport{}, fmt.Errorf("retention needs rollups so charts keep the history, set DB_ROLLUPS")
+	}
+	if c.config.RetentionRaw <= 0 {
+		return db.RetentionReport{}, fmt.Errorf("no raw retention configured, set RETENTION_RAW")
+	}
+
+	report, err := c.db.ApplyRetention(ctx, c.config.RetentionRaw, c.config.RetentionKeepWidth, dryRun)
+	if err != nil {
+		return report, fmt.Errorf("failed to apply retention: %w", err)
+	}
+
+	for _, t := range report.Tickers {
+		c.log.Info("Retention",
+			"dryRun", report.DryRun,
+			"cutoff", report.Cutoff,
+			"ticker", t.Ticker,
+			"rawRows", t.RawRows,
+			"rollupRows", t.RollupRows,
+			"daysDownsampled", t.DaysDownsampled)
+	}
+
+	return report, nil
+}
+
+func (c *Cacher) retention(ctx context.Context) (RunStats, error) {
+	_, err := c.Retention(ctx, c.config.RetentionDryRun)
+	return RunStats{}, err
+}
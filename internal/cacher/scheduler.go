@@ -0,0 +1,275 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what happens when a job is due while its previous run
+// is still in progress.
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the due run.
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue runs the job again as soon as the previous run finishes.
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapAllow starts the due run alongside the previous one.
+	OverlapAllow OverlapPolicy = "allow"
+)
+
+// Schedule configures when and how a job runs.
+type Schedule struct {
+	// Interval between runs. A job with no interval is never run.
+	Interval time.Duration
+	// Jitter is the maximum random delay added to every interval.
+	Jitter time.Duration
+	// Timeout bounds a single run, zero means no timeout.
+	Timeout time.Duration
+	Overlap OverlapPolicy
+}
+
+// RunStats is what a job reports about a single run.
+type RunStats struct {
+	RowsInserted    int
+	RowsSkipped     int
+	UpstreamLatency time.Duration
+}
+
+// RunResult describes a finished job run.
+type RunResult struct {
+	Job        string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Stats      RunStats
+	Err        error
+}
+
+// Job is a unit of work run periodically by the Scheduler.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) (RunStats, error)
+}
+
+// JobStatus is a snapshot of a job's run history. Queued is set when a run is
+// due once the running one finishes.
+type JobStatus struct {
+	Name                string
+	Schedule            Schedule
+	Running             int
+	Queued              bool
+	Runs                int
+	Failures            int
+	Skipped             int
+	ConsecutiveFailures int
+	LastStarted         time.Time
+	LastFinished        time.Time
+	LastDuration        time.Duration
+	LastError           string
+	LastStats           RunStats
+	NextRun             time.Time
+}
+
+type scheduledJob struct {
+	job    Job
+	status JobStatus
+}
+
+// Scheduler runs registered jobs on their own intervals.
+type Scheduler struct {
+	log      *slog.Logger
+	onFinish func(RunResult)
+	mu       sync.Mutex
+	jobs     []*scheduledJob
+	wg       sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler. onFinish, if not nil, is called after
+// every job run, outside of the scheduler lock.
+func NewScheduler(log *slog.Logger, onFinish func(RunResult)) *Scheduler {
+	return &Scheduler{
+		log:      log,
+		onFinish: onFinish,
+	}
+}
+
+// Register adds a job to the scheduler. Jobs without an interval are accepted
+// but never run, which is how jobs are disabled through config.
+func (s *Scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run func")
+	}
+
+	for _, j := range s.jobs {
+		if j.job.Name == job.Name {
+			return fmt.Errorf("job %s is already registered", job.Name)
+		}
+	}
+
+	switch job.Schedule.Overlap {
+	case "":
+		job.Schedule.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return fmt.Errorf("job %s has unknown overlap policy %q", job.Name, job.Schedule.Overlap)
+	}
+
+	s.jobs = append(s.jobs, &scheduledJob{
+		job:    job,
+		status: JobStatus{Name: job.Name, Schedule: job.Schedule},
+	})
+	return nil
+}
+
+// Run starts every enabled job, running each once straight away, and blocks
+// until ctx is cancelled and all in-flight runs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := slices.Clone(s.jobs)
+	s.mu.Unlock()
+
+	var loops sync.WaitGroup
+	for _, j := range jobs {
+		if j.job.Schedule.Interval <= 0 {
+			s.log.Info("Job disabled", "job", j.job.Name)
+			continue
+		}
+
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, j)
+		}()
+	}
+
+	loops.Wait()
+	s.wg.Wait()
+}
+
+// Status returns the status of every registered job.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *scheduledJob) {
+	for {
+		s.trigger(ctx, j)
+
+		delay := j.job.Schedule.Interval
+		if j.job.Schedule.Jitter > 0 {
+			delay += rand.N(j.job.Schedule.Jitter)
+		}
+
+		s.mu.Lock()
+		j.status.NextRun = time.Now().Add(delay)
+		status := j.status
+		s.mu.Unlock()
+
+		s.log.Info("Job status", "job", status.Name, "running", status.Running, "queued", status.Queued,
+			"runs", status.Runs, "failures", status.Failures, "skipped", status.Skipped,
+			"consecutiveFailures", status.ConsecutiveFailures, "lastError", status.LastError,
+			"nextRun", status.NextRun)
+
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// trigger starts a run of the job unless its overlap policy says otherwise.
+func (s *Scheduler) trigger(ctx context.Context, j *scheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.status.Running > 0 {
+		switch j.job.Schedule.Overlap {
+		case OverlapSkip:
+			j.status.Skipped++
+			s.log.Warn("Skipping job, previous run still in progress", "job", j.job.Name)
+			return
+		case OverlapQueue:
+			j.status.Queued = true
+			return
+		}
+	}
+
+	s.start(ctx, j)
+}
+
+// start runs the job in a new goroutine, s.mu must be held.
+func (s *Scheduler) start(ctx context.Context, j *scheduledJob) {
+	j.status.Running++
+	j.status.LastStarted = time.Now()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		started := time.Now()
+		stats, err := s.run(ctx, j)
+		finished := time.Now()
+		duration := finished.Sub(started)
+
+		if s.onFinish != nil {
+			s.onFinish(RunResult{
+				Job:        j.job.Name,
+				StartedAt:  started,
+				FinishedAt: finished,
+				Stats:      stats,
+				Err:        err,
+			})
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		j.status.Running--
+		j.status.Runs++
+		j.status.LastFinished = finished
+		j.status.LastDuration = duration
+		j.status.LastStats = stats
+		if err != nil {
+			j.status.Failures++
+			j.status.ConsecutiveFailures++
+			j.status.LastError = err.Error()
+			s.log.Error("Job failed", "job", j.job.Name, "duration", duration,
+				"consecutiveFailures", j.status.ConsecutiveFailures, "error", err)
+		} else {
+			j.status.ConsecutiveFailures = 0
+			j.status.LastError = ""
+			s.log.Info("Job done", "job", j.job.Name, "duration", duration,
+				"inserted", stats.RowsInserted, "skipped", stats.RowsSkipped)
+		}
+
+		if j.status.Queued && ctx.Err() == nil {
+			j.status.Queued = false
+			s.start(ctx, j)
+		}
+	}()
+}
+
+func (s *Scheduler) run(ctx context.Context, j *scheduledJob) (RunStats, error) {
+	if j.job.Schedule.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.job.Schedule.Timeout)
+		defer cancel()
+	}
+
+	return j.job.Run(ctx)
+}
@@ -0,0 +1,88 @@
+package cacher
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// blockingJob returns a job whose runs each wait for a value on release, and
+// a channel receiving a value as every run starts.
+func blockingJob(overlap OverlapPolicy) (Job, chan struct{}, chan struct{}) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	return Job{
+		Name:     "job",
+		Schedule: Schedule{Interval: time.Hour, Overlap: overlap},
+		Run: func(ctx context.Context) (RunStats, error) {
+			started <- struct{}{}
+			<-release
+			return RunStats{}, nil
+		},
+	}, started, release
+}
+
+func newTestScheduler(t *testing.T, job Job) *Scheduler {
+	s := NewScheduler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if err := s.Register(job); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSchedulerOverlapSkip(t *testing.T) {
+	job, started, release := blockingJob(OverlapSkip)
+	s := newTestScheduler(t, job)
+	ctx := context.Background()
+
+	s.trigger(ctx, s.jobs[0])
+	<-started
+	s.trigger(ctx, s.jobs[0])
+
+	status := s.Status()[0]
+	if status.Running != 1 || status.Skipped != 1 || status.Queued {
+		t.Errorf("status while running = %+v, want 1 running, 1 skipped and none queued", status)
+	}
+
+	close(release)
+	s.wg.Wait()
+
+	status = s.Status()[0]
+	if status.Running != 0 || status.Runs != 1 || status.Skipped != 1 {
+		t.Errorf("status after run = %+v, want 1 run and 1 skipped", status)
+	}
+}
+
+func TestSchedulerOverlapQueue(t *testing.T) {
+	job, started, release := blockingJob(OverlapQueue)
+	s := newTestScheduler(t, job)
+	ctx := context.Background()
+
+	s.trigger(ctx, s.jobs[0])
+	<-started
+	s.trigger(ctx, s.jobs[0])
+
+	status := s.Status()[0]
+	if status.Running != 1 || !status.Queued || status.Skipped != 0 {
+		t.Errorf("status while running = %+v, want 1 running, queued and none skipped", status)
+	}
+
+	// The queued run starts once the first one finishes.
+	release <- struct{}{}
+	<-started
+
+	status = s.Status()[0]
+	if status.Running != 1 || status.Queued || status.Runs != 1 {
+		t.Errorf("status while queued run is running = %+v, want 1 running, 1 run and none queued", status)
+	}
+
+	close(release)
+	s.wg.Wait()
+
+	status = s.Status()[0]
+	if status.Running != 0 || status.Runs != 2 {
+		t.Errorf("status after runs = %+v, want 2 runs", status)
+	}
+}
@@ -2,73 +2,260 @@ package cacher
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/JamesTiberiusKirk/fishstox/internal/config"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
 )
 
+const (
+	JobPricesHour  = "prices_hour"
+	JobPricesDay   = "prices_day"
+	JobPricesWeek  = "prices_week"
+	JobPricesMax   = "prices_max"
+	JobStocks      = "stocks"
+	JobLeaderboard = "leaderboard"
+	JobBackfill    = "backfill"
+	JobEventsPrune = "events_prune"
+	JobRetention   = "retention"
+)
+
 type Cacher struct {
-	log *slog.Logger
-	db  *db.Client
+	log       *slog.Logger
+	db        *db.Client
+	source    PriceSource
+	bus       *events.Bus
+	config    config.Config
+	scheduler *Scheduler
+
+	mu              sync.Mutex
+	driftSignatures map[string]string
 }
 
-func NewCacher(log *slog.Logger, db *db.Client) *Cacher {
-	return &Cacher{
-		log: log,
-		db:  db,
+func NewCacher(log *slog.Logger, db *db.Client, source PriceSource, bus *events.Bus, config config.Config) *Cacher {
+	c := &Cacher{
+		log:             log,
+		db:              db,
+		source:          source,
+		bus:             bus,
+		config:          config,
+		driftSignatures: map[string]string{},
+	}
+	c.scheduler = NewScheduler(log, c.recordRun)
+	if dr, ok := source.(driftReporter); ok {
+		dr.SetDriftHandler(c.recordDrift)
 	}
+	return c
 }
 
-func (c *Cacher) processPrices(data stox.PriceData) {
-	for ticker, timeseries := range data.Prices {
-		for ts, price := range timeseries {
-			err := c.db.AddStockData(ticker, ts, price)
-			if err != nil {
-				c.log.Error("Error getting price data from stox", "error", err)
-				continue
-			}
+// Scrape registers every scrape job with the scheduler and runs them on their
+// configured schedules until ctx is cancelled.
+func (c *Cacher) Scrape(ctx context.Context) error {
+	jobs := []Job{
+		c.pricesJob(JobPricesHour, stox.PriceIntervalHour),
+		c.pricesJob(JobPricesDay, stox.PriceIntervalDay),
+		c.pricesJob(JobPricesWeek, stox.PriceIntervalWeek),
+		c.pricesJob(JobPricesMax, stox.PriceIntervalMax),
+		{Name: JobStocks, Schedule: c.schedule(JobStocks), Run: c.snapshotStocks},
+		{Name: JobLeaderboard, Schedule: c.schedule(JobLeaderboard), Run: c.recordLeaderboard},
+		{Name: JobBackfill, Schedule: c.schedule(JobBackfill), Run: c.backfill},
+		{Name: JobEventsPrune, Schedule: c.schedule(JobEventsPrune), Run: c.pruneEvents},
+	}
+
+	// Raw prices are only removed when a retention is configured and rollups
+	// are read to keep the history on the charts, timescale enforces its own.
+	if c.config.RetentionRaw > 0 && !c.config.DbTimescale && c.config.DbRollups {
+		jobs = append(jobs, Job{Name: JobRetention, Schedule: c.schedule(JobRetention), Run: c.retention})
+	}
+
+	for _, job := range jobs {
+		if err := c.scheduler.Register(job); err != nil {
+			return fmt.Errorf("failed to register job: %w", err)
 		}
 	}
+
+	c.scheduler.Run(ctx)
+	c.log.Info("Scraper stopped")
+	return nil
 }
 
-func (c *Cacher) Scrape(ctx context.Context) {
-	intervals := []stox.PriceInterval{stox.PriceIntervalMax, stox.PriceIntervalWeek,
-		stox.PriceIntervalDay, stox.PriceIntervalHour}
-
-	for {
-		c.log.Info("Scraping interval")
-		for _, i := range intervals {
-			c.log.Info("Scraping", "interval", string(i))
-			data, err := stox.GetPriceData(i)
-			if err != nil {
-				c.log.Error("Error getting price data from stox", "error", err)
-				continue
-			}
-
-			c.processPrices(data)
+// recordRun writes a finished job run to the scrape_runs audit log. It is
+// recorded even when the run was cancelled by shutdown.
+func (c *Cacher) recordRun(r RunResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	run := models.ScrapeRun{
+		Job:             r.Job,
+		StartedAt:       r.StartedAt,
+		FinishedAt:      r.FinishedAt,
+		Status:          models.ScrapeRunSuccess,
+		RowsInserted:    r.Stats.RowsInserted,
+		RowsSkipped:     r.Stats.RowsSkipped,
+		UpstreamLatency: r.Stats.UpstreamLatency,
+	}
+	if r.Err != nil {
+		run.Status = models.ScrapeRunFailed
+		if errors.Is(r.Err, context.Canceled) {
+			run.Status = models.ScrapeRunCancelled
 		}
+		run.Error = r.Err.Error()
+	}
+
+	if err := c.db.AddScrapeRun(ctx, run); err != nil {
+		c.log.Error("Error recording scrape run", "job", r.Job, "error", err)
+	}
 
-		c.log.Info("Done scraping interval")
+	if run.Status == models.ScrapeRunFailed {
+		c.publish(ctx, events.ScrapeFailed{Job: r.Job, StartedAt: r.StartedAt, Error: run.Error})
+	}
+}
+
+// publish sends an event on the bus. Failures are only logged as the data the
+// event announces is already stored.
+func (c *Cacher) publish(ctx context.Context, p events.Payload) {
+	if _, err := c.bus.Publish(ctx, p); err != nil {
+		c.log.Error("Error publishing event", "type", p.EventType(), "error", err)
+	}
+}
+
+func (c *Cacher) schedule(job string) Schedule {
+	jc := c.config.Jobs[job]
+	return Schedule{
+		Interval: jc.Interval,
+		Jitter:   jc.Jitter,
+		Timeout:  jc.Timeout,
+		Overlap:  OverlapPolicy(jc.Overlap),
+	}
+}
 
-		time.Sleep(10 * time.Minute)
+func (c *Cacher) pricesJob(name string, interval stox.PriceInterval) Job {
+	return Job{
+		Name:     name,
+		Schedule: c.schedule(name),
+		Run: func(ctx context.Context) (RunStats, error) {
+			return c.scrapePrices(ctx, interval)
+		},
 	}
 }
 
-func (c *Cacher) CacheStoxData(ctx context.Context) {
-	for {
-		c.log.Info("Caching stox price data on hour interval")
-		data, err := stox.GetPriceData(stox.PriceIntervalHour)
-		if err != nil {
-			c.log.Error("Error getting price data from stox", "error", err)
-			continue
+// processPrices writes the price data to the db in a single transaction, which
+// also stores the new prices events of every ticker and refreshes the rollups
+// around them. If ctx is cancelled the
+// transaction is rolled back and the whole payload is picked up again on the
+// next scrape.
+func (c *Cacher) processPrices(ctx context.Context, data stox.PriceData) (db.IngestResult, error) {
+	res, err := c.db.AddPriceData(ctx, data)
+	if err != nil {
+		return res, fmt.Errorf("failed to ingest prices: %w", err)
+	}
+
+	// Prices are ordered by ticker, so each ticker is a contiguous run.
+	for prices := res.Prices; len(prices) > 0; {
+		n := 1
+		for n < len(prices) && prices[n].Ticker == prices[0].Ticker {
+			n++
 		}
 
-		go c.processPrices(data)
+		c.checkPriceMove(ctx, prices[:n])
+		prices = prices[n:]
+	}
+
+	return res, nil
+}
+
+func (c *Cacher) scrapePrices(ctx context.Context, interval stox.PriceInterval) (RunStats, error) {
+	var stats RunStats
+
+	start := time.Now()
+	data, err := c.source.GetPriceData(ctx, interval)
+	stats.UpstreamLatency = time.Since(start)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get price data from source: %w", err)
+	}
+
+	res, err := c.processPrices(ctx, data)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.RowsInserted = res.Inserted
+	stats.RowsSkipped = res.Skipped
+	return stats, nil
+}
+
+func (c *Cacher) snapshotStocks(ctx context.Context) (RunStats, error) {
+	var stats RunStats
+
+	at := time.Now()
+	resp, err := c.source.GetStocks(ctx)
+	stats.UpstreamLatency = time.Since(at)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get stocks from source: %w", err)
+	}
+
+	n, err := c.db.AddStockSnapshots(ctx, at, resp.Stocks)
+	if err != nil {
+		return stats, fmt.Errorf("failed to store stock snapshots: %w", err)
+	}
+
+	if err := c.db.UpdateCatalog(ctx, at, resp.Stocks); err != nil {
+		return stats, fmt.Errorf("failed to update tickers catalog: %w", err)
+	}
+
+	c.publish(ctx, events.NewSnapshot{At: at, Stocks: n})
+
+	stats.RowsInserted = n
+	stats.RowsSkipped = len(resp.Stocks) - n
+	return stats, nil
+}
+
+func (c *Cacher) recordLeaderboard(ctx context.Context) (RunStats, error) {
+	var stats RunStats
+
+	at := time.Now()
+	resp, err := c.source.GetPortfolioValues(ctx)
+	stats.UpstreamLatency = time.Since(at)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get leaderboard from source: %w", err)
+	}
+
+	res, err := c.db.AddLeaderboard(ctx, at, resp.PortfolioValues)
+	if err != nil {
+		return stats, fmt.Errorf("failed to store leaderboard: %w", err)
+	}
+
+	stats.RowsInserted = res.Users
+	return stats, nil
+}
+
+// pruneEvents deletes events older than the configured retention.
+func (c *Cacher) pruneEvents(ctx context.Context) (RunStats, error) {
+	n, err := c.db.DeleteEventsBefore(ctx, time.Now().Add(-c.config.EventsRetention))
+	if err != nil {
+		return RunStats{}, fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	c.log.Info("pruned events", "deleted", n)
+	return RunStats{}, nil
+}
 
-		c.log.Info("Done caching stox price data")
+// sleep waits for d and reports false if ctx was cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
 
-		time.Sleep(1 * time.Minute)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
 	}
 }
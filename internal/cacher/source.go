@@ -0,0 +1,27 @@
+package cacher
+
+import (
+	"context"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// PriceSource is where the cacher gets market data from. The fishtank API
+// client is the production implementation, see the sources package for the
+// offline ones.
+type PriceSource interface {
+	GetPriceData(ctx context.Context, interval stox.PriceInterval) (stox.PriceData, error)
+	GetStocks(ctx context.Context) (*stox.StocksResponse, error)
+	GetPortfolioValues(ctx context.Context) (*stox.PortfolioValuesResponse, error)
+}
+
+// driftReporter is implemented by sources that validate upstream payloads.
+type driftReporter interface {
+	SetDriftHandler(fn func(ctx context.Context, d stox.Drift))
+}
+
+// payloadReporter is implemented by sources that can hand out raw payloads
+// for archiving.
+type payloadReporter interface {
+	SetPayloadHandler(fn func(ctx context.Context, p stox.Payload))
+}
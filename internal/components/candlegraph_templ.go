@@ -68,7 +68,7 @@ func CandleGraph(props CandleGraphProps) templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<script>\n                function initChart(canvasID, chartDataString, ticker) {\n                    const chartData = JSON.parse(chartDataString);\n\n                    // Ensure timestamps and values are properly aligned\n                    if (chartData.timestamps.length !== chartData.values.length) {\n                        console.error(\"Mismatched timestamps and values arrays\");\n                        return;\n                    }\n\n                    // Convert raw timestamps (in milliseconds) to DateTime objects\n                    const timestamps = chartData.timestamps.map(timestamp => {\n                        const validTimestamp = Number(timestamp);\n                        if (isNaN(validTimestamp)) {\n                            console.error(\"Invalid timestamp:\", timestamp);\n                            return null;\n                        }\n                        const dt = luxon.DateTime.fromMillis(validTimestamp);\n                        return dt.isValid ? dt.toMillis() : null;\n                    }).filter(ts => ts !== null);\n\n                    new Chart(document.getElementById(canvasID), {\n                        type: 'line',\n                        data: {\n                            labels: timestamps,  // Use the converted timestamps\n                            datasets: [{\n                                label: ticker,\n                                data: chartData.values,  // Ensure this is correctly aligned with timestamps\n                                borderColor: 'rgba(75, 192, 192, 1)',\n                                backgroundColor: 'rgba(75, 192, 192, 0.2)',\n                                fill: true,\n                            }],\n                        },\n                        options: {\n                            responsive: true,\n                            scales: {\n                                x: {\n                                    type: 'time',\n                                    time: {\n                                        unit: 'minute',\n                                        tooltipFormat: 'll HH:mm',\n                                        displayFormats: {\n                                            minute: 'dd/MM HH:mm',\n                                            hour: 'dd/MM HH:mm',\n                                            day: 'dd/MM',\n                                        }\n                                    },\n                                    ticks: {\n                                        source: 'data',\n                                        callback: function(value, index, values) {\n                                            return value\n                                        }\n                                    },\n                                },\n                                y: {\n                                    beginAtZero: false,\n                                },\n                            },\n                            plugins: {\n                                tooltip: {\n                                    callbacks: {\n                                        label: function(tooltipItem) {\n                                            const date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n                                            return date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${tooltipItem.raw.y}` : '';\n                                        }\n                                    }\n                                }\n                            }\n                        }\n                    });\n                }\n                </script>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<script>\n                function initChart(canvasID, chartDataString, ticker) {\n                    const chartData = JSON.parse(chartDataString);\n\n                    // Ensure timestamps and values are properly aligned\n                    if (chartData.timestamps.length !== chartData.values.length) {\n                        console.error(\"Mismatched timestamps and values arrays\");\n                        return;\n                    }\n\n                    // Convert raw timestamps (in milliseconds) to DateTime objects\n                    const timestamps = chartData.timestamps.map(timestamp => {\n                        const validTimestamp = Number(timestamp);\n                        if (isNaN(validTimestamp)) {\n                            console.error(\"Invalid timestamp:\", timestamp);\n                            return null;\n                        }\n                        const dt = luxon.DateTime.fromMillis(validTimestamp);\n                        return dt.isValid ? dt.toMillis() : null;\n                    }).filter(ts => ts !== null);\n\n                    new Chart(document.getElementById(canvasID), {\n                        type: 'line',\n                        data: {\n                            labels: timestamps,  // Use the converted timestamps\n                            datasets: [{\n                                label: ticker,\n                                data: chartData.values,  // Ensure this is correctly aligned with timestamps\n                                borderColor: 'rgba(75, 192, 192, 1)',\n                                backgroundColor: 'rgba(75, 192, 192, 0.2)',\n                                fill: true,\n                            }],\n                        },\n                        options: {\n                            responsive: true,\n                            scales: {\n                                x: {\n                                    type: 'time',\n                                    time: {\n                                        unit: 'minute',\n                                        tooltipFormat: 'll HH:mm',\n                                        displayFormats: {\n                                            minute: 'dd/MM HH:mm',\n                                            hour: 'dd/MM HH:mm',\n                                            day: 'dd/MM',\n                                        }\n                                    },\n                                    ticks: {\n                                        source: 'data',\n                                        callback: function(value, index, values) {\n                                            return value\n                                        }\n                                    },\n                                },\n                                y: {\n                                    beginAtZero: false,\n                                },\n                            },\n                            plugins: {\n                                tooltip: {\n                                    callbacks: {\n                                        label: function(tooltipItem) {\n                                            const date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n                                            return date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${Number(tooltipItem.raw.y).toLocaleString('en-US', { minimumFractionDigits: tooltipItem.raw.y % 1 ? 2 : 0, maximumFractionDigits: 2 })}` : '';\n                                        }\n                                    }\n                                }\n                            }\n                        }\n                    });\n                }\n                </script>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
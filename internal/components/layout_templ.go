@@ -88,7 +88,7 @@ func Layout(r *http.Request, props LayoutProps) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\"><div style=\"display: flex;\"><a href=\"/\" style=\"display: flex;\"><h1 class=\"noDecoration\" style=\"color: var(--text); text-decoration: none; /* no underline */ padding-left: 10px;\">FishStox</h1></a></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\"><div style=\"display: flex;\"><a href=\"/\" style=\"display: flex;\"><h1 class=\"noDecoration\" style=\"color: var(--text); text-decoration: none; /* no underline */ padding-left: 10px;\">FishStox</h1></a> <a href=\"/scrapes\" style=\"margin-left: auto; align-self: center;\">Scrapes</a></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -16,6 +16,9 @@ type SimpleGraphProps struct {
 	ID          string
 	Prices      []models.StockPrice
 	TickerQuery string
+	// LiveURL is an SSE endpoint streaming new prices to append to the chart,
+	// the chart is static when empty.
+	LiveURL string
 }
 
 func SimpleGraph(props SimpleGraphProps) templ.Component {
@@ -46,7 +49,7 @@ func SimpleGraph(props SimpleGraphProps) templ.Component {
 		var templ_7745c5c3_Var2 string
 		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(props.ID + "_simple-chart")
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/components/simplegraph.templ`, Line: 16, Col: 41}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/components/simplegraph.templ`, Line: 19, Col: 41}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
@@ -56,7 +59,39 @@ func SimpleGraph(props SimpleGraphProps) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Var3 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		if props.LiveURL != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div id=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var3 string
+			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(props.ID + "_simple-live")
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/components/simplegraph.templ`, Line: 22, Col: 37}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" hx-ext=\"sse\" sse-connect=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 string
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(props.LiveURL)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/components/simplegraph.templ`, Line: 22, Col: 80}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\" sse-swap=\"prices\" style=\"display: none;\"></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Var5 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 			if !templ_7745c5c3_IsBuffer {
@@ -68,17 +103,17 @@ func SimpleGraph(props SimpleGraphProps) templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<script>\n                function initChart(canvasID, chartDataString, ticker) {\n                    const chartData = JSON.parse(chartDataString);\n\n                    // Ensure timestamps and values are properly aligned\n                    if (chartData.timestamps.length !== chartData.values.length) {\n                        console.error(\"Mismatched timestamps and values arrays\");\n                        return;\n                    }\n\n                    // Convert raw timestamps (in milliseconds) to DateTime objects\n                    const timestamps = chartData.timestamps.map(timestamp => {\n                        const validTimestamp = Number(timestamp);\n                        if (isNaN(validTimestamp)) {\n                            console.error(\"Invalid timestamp:\", timestamp);\n                            return null;\n                        }\n                        const dt = luxon.DateTime.fromMillis(validTimestamp);\n                        return dt.isValid ? dt.toMillis() : null;\n                    }).filter(ts => ts !== null);\n\n                    new Chart(document.getElementById(canvasID), {\n                        type: 'line',\n                        data: {\n                            labels: timestamps,  // Use the converted timestamps\n                            datasets: [{\n                                label: ticker,\n                                data: chartData.values,  // Ensure this is correctly aligned with timestamps\n                                borderColor: 'rgba(75, 192, 192, 1)',\n                                backgroundColor: 'rgba(75, 192, 192, 0.2)',\n                                fill: true,\n                            }],\n                        },\n                        options: {\n                            responsive: true,\n                            scales: {\n                                x: {\n                                    type: 'time',\n                                    time: {\n                                        unit: 'minute',\n                                        tooltipFormat: 'll HH:mm',\n                                        displayFormats: {\n                                            minute: 'dd/MM HH:mm',\n                                            hour: 'dd/MM HH:mm',\n                                            day: 'dd/MM',\n                                        }\n                                    },\n                                    ticks: {\n                                        source: 'data',\n                                        callback: function(value, index, values) {\n                                            return value\n                                        }\n                                    },\n                                },\n                                y: {\n                                    beginAtZero: false,\n                                },\n                            },\n                            plugins: {\n                                tooltip: {\n                                    callbacks: {\n                                        label: function(tooltipItem) {\n                                            const date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n                                            return date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${tooltipItem.raw.y}` : '';\n                                        }\n                                    }\n                                }\n                            }\n                        }\n                    });\n                }\n                </script>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<script>\n                function initChart(canvasID, chartDataString, ticker, liveID) {\n                    const chartData = JSON.parse(chartDataString);\n\n                    // Ensure timestamps and values are properly aligned\n                    if (chartData.timestamps.length !== chartData.values.length) {\n                        console.error(\"Mismatched timestamps and values arrays\");\n                        return;\n                    }\n\n                    // Convert raw timestamps (in milliseconds) to DateTime objects\n                    const timestamps = chartData.timestamps.map(timestamp => {\n                        const validTimestamp = Number(timestamp);\n                        if (isNaN(validTimestamp)) {\n                            console.error(\"Invalid timestamp:\", timestamp);\n                            return null;\n                        }\n                        const dt = luxon.DateTime.fromMillis(validTimestamp);\n                        return dt.isValid ? dt.toMillis() : null;\n                    }).filter(ts => ts !== null);\n\n                    const chart = new Chart(document.getElementById(canvasID), {\n                        type: 'line',\n                        data: {\n                            labels: timestamps,  // Use the converted timestamps\n                            datasets: [{\n                                label: ticker,\n                                data: chartData.values,  // Ensure this is correctly aligned with timestamps\n                                borderColor: 'rgba(75, 192, 192, 1)',\n                                backgroundColor: 'rgba(75, 192, 192, 0.2)',\n                                fill: true,\n                            }],\n                        },\n                        options: {\n                            responsive: true,\n                            scales: {\n                                x: {\n                                    type: 'time',\n                                    time: {\n                                        unit: 'minute',\n                                        tooltipFormat: 'll HH:mm',\n                                        displayFormats: {\n                                            minute: 'dd/MM HH:mm',\n                                            hour: 'dd/MM HH:mm',\n                                            day: 'dd/MM',\n                                        }\n                                    },\n                                    ticks: {\n                                        source: 'data',\n                                        callback: function(value, index, values) {\n                                            return value\n                                        }\n                                    },\n                                },\n                                y: {\n                                    beginAtZero: false,\n                                },\n                            },\n                            plugins: {\n                                tooltip: {\n                                    callbacks: {\n                                        label: function(tooltipItem) {\n                                            const date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n                                            return date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${Number(tooltipItem.raw.y).toLocaleString('en-US', { minimumFractionDigits: tooltipItem.raw.y % 1 ? 2 : 0, maximumFractionDigits: 2 })}` : '';\n                                        }\n                                    }\n                                }\n                            }\n                        }\n                    });\n\n                    // Append prices streamed by the live element instead of\n                    // letting htmx swap them into the page.\n                    const live = liveID && document.getElementById(liveID);\n                    if (live) {\n                        live.addEventListener('htmx:sseBeforeMessage', function(evt) {\n                            evt.preventDefault();\n                            JSON.parse(evt.detail.data).forEach(p => {\n                                chart.data.labels.push(p.timestamp);\n                                chart.data.datasets[0].data.push(p.value);\n                            });\n                            chart.update('none');\n                        });\n                    }\n                }\n                </script>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			return nil
 		})
-		templ_7745c5c3_Err = simpleGraphHandle.Once().Render(templ.WithChildren(ctx, templ_7745c5c3_Var3), templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = simpleGraphHandle.Once().Render(templ.WithChildren(ctx, templ_7745c5c3_Var5), templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templ.JSFuncCall("initChart", props.ID+"_simple-chart", util.GenerateChartData(props.Prices), props.TickerQuery).Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = templ.JSFuncCall("initChart", props.ID+"_simple-chart", util.GenerateChartData(props.Prices), props.TickerQuery, simpleGraphLiveID(props)).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -86,4 +121,11 @@ func SimpleGraph(props SimpleGraphProps) templ.Component {
 	})
 }
 
+func simpleGraphLiveID(props SimpleGraphProps) string {
+	if props.LiveURL == "" {
+		return ""
+	}
+	return props.ID + "_simple-live"
+}
+
 var _ = templruntime.GeneratedTemplate
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +14,94 @@ type Config struct {
 	DbPass string
 	DbHost string
 	DbName string
+
+	// Connection pool and query bounds, zero values keep the database/sql
+	// defaults and leave queries unbounded.
+	DbMaxOpenConns    int
+	DbMaxIdleConns    int
+	DbConnMaxLifetime time.Duration
+	DbConnMaxIdleTime time.Duration
+	DbQueryTimeout    time.Duration
+
+	// DbMigrate has the binaries bring the schema up to date at startup.
+	// Disable it to leave schema changes to `fishstox migrate up`.
+	DbMigrate bool
+
+	// DbTimescale stores prices in a TimescaleDB hypertable with continuous
+	// aggregates, compressing chunks after TimescaleCompressAfter and dropping
+	// them after TimescaleDropAfter when set.
+	DbTimescale            bool
+	TimescaleCompressAfter time.Duration
+	TimescaleDropAfter     time.Duration
+
+	// DbRollups has the scraper maintain OHLC rollup tables that long range
+	// price queries read from, ignored when DbTimescale is set.
+	DbRollups bool
+
+	// RetentionRaw is how long raw prices are kept before being downsampled
+	// to rollups of RetentionKeepWidth and coarser, 0 keeps them forever. It
+	// needs DbRollups, without it the charts would lose the removed history.
+	// RetentionDryRun only reports what the retention job would remove.
+	RetentionRaw       time.Duration
+	RetentionKeepWidth time.Duration
+	RetentionDryRun    bool
+
+	StoxBaseURL   string
+	StoxUserAgent string
+	StoxTimeout   time.Duration
+
+	StoxMaxAttempts    int
+	StoxRetryBaseDelay time.Duration
+	StoxRetryMaxDelay  time.Duration
+	StoxRateLimit      float64
+	StoxRateBurst      int
+
+	Jobs map[string]JobConfig
+
+	BackfillLookback time.Duration
+	BackfillMaxGap   time.Duration
+
+	// ArchiveDir is where raw upstream payloads are archived, archiving is
+	// disabled when empty.
+	ArchiveDir string
+
+	// PriceSource selects where market data comes from: fishtank, file or
+	// synthetic.
+	PriceSource      string
+	PriceSourceFile  string
+	SyntheticSeed    uint64
+	SyntheticTickers []string
+
+	// EventsRetention is how long published events are kept for subscribers
+	// to catch up on.
+	EventsRetention time.Duration
+
+	// AlertPriceMove is the percentage a ticker's newly ingested prices must
+	// move by within AlertWindow to fire an alert, alerts are disabled when 0.
+	AlertPriceMove float64
+	AlertWindow    time.Duration
+}
+
+// JobConfig is the schedule of a scraper job. Every field can be overridden
+// with SCRAPE_<JOB>_INTERVAL, _JITTER, _TIMEOUT and _OVERLAP env vars, and a
+// job is disabled by setting its interval to 0.
+type JobConfig struct {
+	Interval time.Duration
+	Jitter   time.Duration
+	Timeout  time.Duration
+	Overlap  string
+}
+
+var defaultJobs = map[string]JobConfig{
+	"prices_hour":  {Interval: time.Minute, Jitter: 5 * time.Second, Timeout: 2 * time.Minute, Overlap: "skip"},
+	"prices_day":   {Interval: 10 * time.Minute, Jitter: 30 * time.Second, Timeout: 5 * time.Minute, Overlap: "skip"},
+	"prices_week":  {Interval: time.Hour, Jitter: time.Minute, Timeout: 10 * time.Minute, Overlap: "skip"},
+	"prices_max":   {Interval: 6 * time.Hour, Jitter: 5 * time.Minute, Timeout: 30 * time.Minute, Overlap: "skip"},
+	"stocks":       {Interval: time.Minute, Jitter: 5 * time.Second, Timeout: 2 * time.Minute, Overlap: "skip"},
+	"leaderboard":  {Interval: 10 * time.Minute, Jitter: 30 * time.Second, Timeout: 5 * time.Minute, Overlap: "skip"},
+	"backfill":     {Interval: time.Hour, Jitter: 2 * time.Minute, Timeout: 15 * time.Minute, Overlap: "skip"},
+	"events_prune": {Interval: time.Hour, Jitter: 2 * time.Minute, Timeout: 5 * time.Minute, Overlap: "skip"},
+	"retention":    {Interval: 24 * time.Hour, Jitter: 10 * time.Minute, Timeout: 2 * time.Hour, Overlap: "skip"},
 }
 
 func GetConfig() Config {
@@ -36,10 +127,160 @@ func GetConfig() Config {
 		panic("DB_NAME not set")
 	}
 
+	stoxUserAgent := os.Getenv("STOX_USER_AGENT")
+	if stoxUserAgent == "" {
+		stoxUserAgent = "fishstox/" + Version
+	}
+
 	return Config{
 		DbUser: user,
 		DbPass: pass,
 		DbHost: host,
 		DbName: name,
+
+		DbMaxOpenConns:    getInt("DB_MAX_OPEN_CONNS", 10),
+		DbMaxIdleConns:    getInt("DB_MAX_IDLE_CONNS", 5),
+		DbConnMaxLifetime: getDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DbConnMaxIdleTime: getDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DbQueryTimeout:    getDuration("DB_QUERY_TIMEOUT", 30*time.Second),
+
+		DbMigrate: getBool("DB_MIGRATE", true),
+
+		DbTimescale:            getBool("DB_TIMESCALE", false),
+		TimescaleCompressAfter: getDuration("TIMESCALE_COMPRESS_AFTER", 7*24*time.Hour),
+		TimescaleDropAfter:     getDuration("TIMESCALE_DROP_AFTER", 0),
+
+		DbRollups: getBool("DB_ROLLUPS", true),
+
+		RetentionRaw:       getDuration("RETENTION_RAW", 0),
+		RetentionKeepWidth: getDuration("RETENTION_KEEP_WIDTH", time.Hour),
+		RetentionDryRun:    getBool("RETENTION_DRY_RUN", false),
+
+		StoxBaseURL:   os.Getenv("STOX_BASE_URL"),
+		StoxUserAgent: stoxUserAgent,
+		StoxTimeout:   getDuration("STOX_TIMEOUT", 30*time.Second),
+
+		StoxMaxAttempts:    getInt("STOX_MAX_ATTEMPTS", 4),
+		StoxRetryBaseDelay: getDuration("STOX_RETRY_BASE_DELAY", 500*time.Millisecond),
+		StoxRetryMaxDelay:  getDuration("STOX_RETRY_MAX_DELAY", 30*time.Second),
+		StoxRateLimit:      getFloat("STOX_RATE_LIMIT", 1),
+		StoxRateBurst:      getInt("STOX_RATE_BURST", 3),
+
+		Jobs: getJobs(),
+
+		BackfillLookback: getDuration("BACKFILL_LOOKBACK", 7*24*time.Hour),
+		BackfillMaxGap:   getDuration("BACKFILL_MAX_GAP", 10*time.Minute),
+
+		ArchiveDir: os.Getenv("ARCHIVE_DIR"),
+
+		PriceSource:      os.Getenv("PRICE_SOURCE"),
+		PriceSourceFile:  os.Getenv("PRICE_SOURCE_FILE"),
+		SyntheticSeed:    uint64(getInt("SYNTHETIC_SEED", 1)),
+		SyntheticTickers: getList("SYNTHETIC_TICKERS", []string{"DRNC", "FISH", "TANK"}),
+
+		EventsRetention: getDuration("EVENTS_RETENTION", 7*24*time.Hour),
+
+		AlertPriceMove: getFloat("ALERT_PRICE_MOVE", 0),
+		AlertWindow:    getDuration("ALERT_WINDOW", time.Hour),
 	}
 }
+
+func getJobs() map[string]JobConfig {
+	jobs := make(map[string]JobConfig, len(defaultJobs))
+	for name, def := range defaultJobs {
+		prefix := "SCRAPE_" + strings.ToUpper(name) + "_"
+
+		overlap := os.Getenv(prefix + "OVERLAP")
+		if overlap == "" {
+			overlap = def.Overlap
+		}
+
+		jobs[name] = JobConfig{
+			Interval: getDuration(prefix+"INTERVAL", def.Interval),
+			Jitter:   getDuration(prefix+"JITTER", def.Jitter),
+			Timeout:  getDuration(prefix+"TIMEOUT", def.Timeout),
+			Overlap:  overlap,
+		}
+	}
+	return jobs
+}
+
+// getDuration reads a time.ParseDuration formatted env var, falling back to
+// def when it is not set.
+func getDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		panic(key + " is not a valid duration: " + err.Error())
+	}
+
+	return d
+}
+
+// getInt reads an integer env var, falling back to def when it is not set.
+func getInt(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(raw)
+	if err != nil {
+		panic(key + " is not a valid integer: " + err.Error())
+	}
+
+	return i
+}
+
+// getFloat reads a float env var, falling back to def when it is not set.
+func getFloat(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		panic(key + " is not a valid number: " + err.Error())
+	}
+
+	return f
+}
+
+// getBool reads a strconv.ParseBool formatted env var, falling back to def
+// when it is not set.
+func getBool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		panic(key + " is not a valid boolean: " + err.Error())
+	}
+
+	return b
+}
+
+// getList reads a comma separated env var, falling back to def when it is not
+// set.
+func getList(key string, def []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
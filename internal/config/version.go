@@ -1,3 +1,3 @@
 package config
 
-var Version string
+var Version = "devel"
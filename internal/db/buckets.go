@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
+)
+
+// rawBucketSource reads raw prices as single sample buckets, so the same
+// aggregation works over raw prices and rollups.
+const rawBucketSource = `SELECT timestamp AS ts, value AS open, value AS high, value AS low, value AS close,
+	value::DOUBLE PRECISION AS sum, 1 AS samples
+	FROM tickers WHERE ticker = $1 AND timestamp BETWEEN $2 AND $3`
+
+// rollupBucketSource reads a rollup table or view, weighting its averages by
+// sample count so they can be combined.
+const rollupBucketSource = `SELECT bucket AS ts, open, high, low, close,
+	avg * samples AS sum, samples
+	FROM %s WHERE ticker = $1 AND bucket BETWEEN $2 AND $3`
+
+// bucketQuery aggregates a bucket source into buckets starting at the
+// timestamps of a bucket expression.
+const bucketQuery = `SELECT bucket,
+	(array_agg(open ORDER BY ts ASC))[1] AS open,
+	MAX(high) AS high,
+	MIN(low) AS low,
+	(array_agg(close ORDER BY ts DESC))[1] AS close,
+	SUM(sum) / SUM(samples) AS avg,
+	SUM(samples) AS samples
+FROM (
+	SELECT src.*, (EXTRACT(EPOCH FROM %s) * 1000)::BIGINT AS bucket
+	FROM (%s) src
+) binned
+GROUP BY bucket
+ORDER BY bucket ASC`
+
+// fixedBucket bins into buckets of $4 milliseconds aligned to the unix
+// millisecond timestamp $5.
+const fixedBucket = `date_bin(
+		make_interval(secs => $4::BIGINT / 1000.0),
+		to_timestamp(ts / 1000.0),
+		to_timestamp($5::BIGINT / 1000.0)
+	)`
+
+// calendarBucket truncates to the start of the %s in the timezone $4.
+const calendarBucket = `date_trunc('%s', to_timestamp(ts / 1000.0), $4::TEXT)`
+
+// GetPriceBuckets returns the OHLC, average and sample count of a ticker's
+// prices in the buckets of opts, aggregated in SQL. The coarsest rollup that
+// nests in the buckets is read instead of raw prices when one is available.
+func (c *Client) GetPriceBuckets(
+	ctx context.Context,
+	ticker string,
+	opts prices.Options,
+) ([]models.PriceBucket, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	b, err := prices.NewBucketer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{ticker, opts.Range.From.UnixMilli(), opts.Range.To.UnixMilli()}
+
+	var bucket string
+	switch {
+	case opts.Align == prices.AlignCalendar && opts.Width == 24*time.Hour:
+		bucket = fmt.Sprintf(calendarBucket, "day")
+		args = append(args, opts.Range.Location().String())
+	case opts.Align == prices.AlignCalendar:
+		// Postgres weeks start on Monday, like calendar aligned ones.
+		bucket = fmt.Sprintf(calendarBucket, "week")
+		args = append(args, opts.Range.Location().String())
+	case opts.Align == prices.AlignRangeStart:
+		bucket = fixedBucket
+		args = append(args, opts.Width.Milliseconds(), opts.Range.From.UnixMilli())
+	default:
+		bucket = fixedBucket
+		args = append(args, opts.Width.Milliseconds(), 0)
+	}
+
+	source, sourceName := rawBucketSource, "tickers"
+	for i := len(c.rollups) - 1; i >= 0; i-- {
+		if r := c.rollups[i]; opts.Nests(r.width) {
+			source, sourceName = fmt.Sprintf(rollupBucketSource, r.table), r.table
+			break
+		}
+	}
+
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(bucketQuery, bucket, source), args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("ticker", ticker), slog.String("source", sourceName), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query price buckets: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []models.PriceBucket
+	for rows.Next() {
+		bucket := models.PriceBucket{Ticker: ticker}
+		if err := rows.Scan(&bucket.Start, &bucket.Open, &bucket.High, &bucket.Low, &bucket.Close, &bucket.Avg, &bucket.Samples); err != nil {
+			return nil, fmt.Errorf("failed to scan price bucket: %w", err)
+		}
+		bucket.End = b.End(bucket.Start)
+		buckets = append(buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices.Fill(ticker, buckets, opts)
+}
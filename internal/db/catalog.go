@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+var catalogCols = []string{"ticker", "first_seen", "last_seen", "ipo_price", "total_shares", "delisted"}
+
+// UpdateCatalog records every listed stock in the tickers_catalog table and
+// marks tickers missing from the listing as delisted. An empty listing is
+// ignored rather than delisting everything.
+func (c *Client) UpdateCatalog(ctx context.Context, at time.Time, stocks []stox.Stock) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if len(stocks) == 0 {
+		return nil
+	}
+
+	ts := at.UnixMilli()
+	query := c.sq.Insert("tickers_catalog").
+		Columns(catalogCols...).
+		Suffix(`ON CONFLICT (ticker) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			ipo_price = EXCLUDED.ipo_price,
+			total_shares = EXCLUDED.total_shares,
+			delisted = FALSE`)
+	for _, s := range stocks {
+		query = query.Values(s.TickerSymbol, ts, ts, s.IpoPrice, s.TotalShares, false)
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, sqlQuery, args...); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to upsert tickers catalog: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE tickers_catalog SET delisted = TRUE WHERE last_seen < $1 AND NOT delisted", ts,
+	); err != nil {
+		return fmt.Errorf("failed to mark delisted tickers: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertCatalogTickers adds the tickers of newly ingested prices, ordered by
+// ticker, that are missing from the catalog, so their charts work before the
+// next stocks snapshot fills in the rest of their details.
+func (c *Client) insertCatalogTickers(ctx context.Context, tx *sql.Tx, at time.Time, prices []models.StockPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	ts := at.UnixMilli()
+	query := c.sq.Insert("tickers_catalog").
+		Columns(catalogCols...).
+		Suffix("ON CONFLICT (ticker) DO NOTHING")
+	for i, p := range prices {
+		if i == 0 || p.Ticker != prices[i-1].Ticker {
+			query = query.Values(p.Ticker, ts, ts, 0, 0, false)
+		}
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlQuery, args...); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to insert catalog tickers: %w", err)
+	}
+
+	return nil
+}
+
+// ListTickers returns the tickers in the catalog in alphabetical order,
+// leaving out delisted ones unless includeDelisted is set.
+func (c *Client) ListTickers(ctx context.Context, includeDelisted bool) ([]models.TickerInfo, error) {
+	sb := c.sq.Select(catalogCols...).From("tickers_catalog").OrderBy("ticker")
+	if !includeDelisted {
+		sb = sb.Where(squirrel.Eq{"delisted": false})
+	}
+
+	return c.queryCatalog(ctx, sb)
+}
+
+// SearchTickers returns up to limit tickers containing query, case
+// insensitively, with prefix matches first.
+func (c *Client) SearchTickers(ctx context.Context, query string, limit int) ([]models.TickerInfo, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+
+	sb := c.sq.Select(catalogCols...).From("tickers_catalog").
+		Where(squirrel.ILike{"ticker": "%" + escaped + "%"}).
+		OrderByClause("ticker ILIKE ? DESC", escaped+"%").
+		OrderBy("delisted", "ticker").
+		Limit(uint64(limit))
+
+	return c.queryCatalog(ctx, sb)
+}
+
+// GetTicker returns the catalog entry of a ticker, with ok false if it is
+// unknown.
+func (c *Client) GetTicker(ctx context.Context, ticker string) (info models.TickerInfo, ok bool, err error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select(catalogCols...).From("tickers_catalog").
+		Where(squirrel.Eq{"ticker": ticker})
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return info, false, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	info, err = scanTickerInfo(c.db.QueryRowContext(ctx, sqlQuery, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return info, false, nil
+	}
+	if err != nil {
+		return info, false, fmt.Errorf("failed to query ticker: %w", err)
+	}
+
+	return info, true, nil
+}
+
+func (c *Client) queryCatalog(ctx context.Context, sb squirrel.SelectBuilder) ([]models.TickerInfo, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query tickers catalog: %w", err)
+	}
+	defer rows.Close()
+
+	var tickers []models.TickerInfo
+	for rows.Next() {
+		t, err := scanTickerInfo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ticker: %w", err)
+		}
+		tickers = append(tickers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickers, nil
+}
+
+func scanTickerInfo(row interface{ Scan(...any) error }) (models.TickerInfo, error) {
+	var t models.TickerInfo
+	var firstSeen, lastSeen int64
+	if err := row.Scan(&t.Ticker, &firstSeen, &lastSeen, &t.IpoPrice, &t.TotalShares, &t.Delisted); err != nil {
+		return t, err
+	}
+	t.FirstSeen = time.UnixMilli(firstSeen)
+	t.LastSeen = time.UnixMilli(lastSeen)
+	return t, nil
+}
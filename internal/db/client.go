@@ -1,18 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"log/slog"
-	"strconv"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	_ "github.com/lib/pq"
 
 	"github.com/JamesTiberiusKirk/fishstox/internal/models"
-	"github.com/JamesTiberiusKirk/migrator/migrator"
 )
 
 type Client struct {
@@ -21,9 +19,20 @@ type Client struct {
 	db      *sql.DB
 	sq      squirrel.StatementBuilderType
 	now     func() time.Time
+
+	// rollups are the OHLC rollups price queries may read from instead of
+	// raw prices, finest first.
+	rollups []rollup
+
+	// maintainRollups has AddPriceData refresh the rollup tables.
+	maintainRollups bool
+
+	// queryTimeout bounds every query, 0 leaves them unbounded.
+	queryTimeout time.Duration
 }
 
-// InitClient initializes a new database client and pings the DB.
+// InitClient initializes a new database client and pings the DB. The schema
+// is left untouched, see MigrateUp.
 func InitClient(
 	log *slog.Logger,
 	user, pass, host, dbName string,
@@ -40,23 +49,11 @@ func InitClient(
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping the database: %w", err)
-	}
-
-	migrate, err := migrator.NewMigratorWithSqlClient(db, "./internal/db/sql/")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create migrator instance: %w", err)
-	}
-
-	err = migrate.ApplySchemaUp()
-	if err != nil && !errors.Is(err, migrator.ErrSchemaAlreadyInitialised) {
-		return nil, fmt.Errorf("failed to apply schema up: %w", err)
-	}
+	pingCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	err = migrate.ApplyMigration()
-	if err != nil {
-		return nil, fmt.Errorf("failed to apply schema up: %w", err)
+	if err := db.PingContext(pingCtx); err != nil {
+		return nil, fmt.Errorf("failed to ping the database: %w", err)
 	}
 
 	return &Client{
@@ -68,250 +65,59 @@ func InitClient(
 	}, nil
 }
 
-// AddStockData adds stock data for a specific ticker into the stock_data table.
-func (c *Client) AddStockData(ticker string, timestamp string, value int) error {
-	// Build the insert query using squirrel
-	query := c.sq.Insert("tickers").
-		Columns("ticker", "timestamp", "value").
-		Values(ticker, timestamp, value).
-		Suffix("ON CONFLICT (ticker, timestamp) DO NOTHING") // To prevent duplicates on the same timestamp for a ticker.
-
-	// Execute the query
-	sqlQuery, args, err := query.ToSql()
-	if err != nil {
-		c.log.Error("failed to build SQL query", slog.Any("ticker", ticker), slog.Any("timestamp", timestamp), slog.String("error", err.Error()))
-		return fmt.Errorf("failed to build SQL query: %w", err)
-	}
-
-	// Run the query
-	_, err = c.db.Exec(sqlQuery, args...)
-	if err != nil {
-		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.Any("timestamp", timestamp), slog.String("error", err.Error()))
-		return fmt.Errorf("failed to insert stock data: %w", err)
+// SetPool configures the connection pool. Zero values keep the database/sql
+// defaults.
+func (c *Client) SetPool(maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) {
+	if maxOpen > 0 {
+		c.db.SetMaxOpenConns(maxOpen)
 	}
-
-	c.log.Info("added stock data", slog.Any("ticker", ticker), slog.Any("timestamp", timestamp), slog.Int("value", value))
-	return nil
-}
-
-type TimeFrame int
-
-const (
-	TimeFrameHourly TimeFrame = iota
-	TimeFrameDaily
-	TimeFrameWeekly
-	TimeFrameMax
-)
-
-// GetStockPricesByTimeFrame returns prices for a ticker at the specified time frame, evenly spaced.
-func (c *Client) GetStockPricesByTimeFrameOld(
-	ticker string,
-	from, to time.Time,
-	numPrices int, // Number of prices to return
-) ([]models.StockPrice, error) {
-	// Sanity check for numPrices
-	if numPrices <= 0 {
-		return nil, fmt.Errorf("numPrices must be greater than 0")
+	if maxIdle > 0 {
+		c.db.SetMaxIdleConns(maxIdle)
 	}
-
-	// Calculate the total duration in milliseconds
-	duration := to.Sub(from).Milliseconds()
-
-	// Calculate the time interval between each price in milliseconds
-	interval := duration / int64(numPrices-1)
-
-	selectCols := []string{"ticker", "timestamp", "value"}
-
-	// We will select prices based on the time intervals
-	sb := c.sq.Select(selectCols...).From("tickers").
-		Where(squirrel.Eq{"ticker": ticker}).
-		Where("timestamp BETWEEN ? AND ?", from.UnixNano()/int64(time.Millisecond), to.UnixNano()/int64(time.Millisecond)).
-		OrderBy("timestamp ASC") // Order by timestamp to ensure chronological order
-
-	sqlQuery, args, err := sb.ToSql()
-	if err != nil {
-		c.log.Error("failed to build SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to build SQL query: %w", err)
-	}
-
-	rows, err := c.db.Query(sqlQuery, args...)
-	if err != nil {
-		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to query stock data: %w", err)
-	}
-	defer rows.Close()
-
-	var prices []models.StockPrice
-	var lastTimestamp int64
-
-	// Use this to track the number of records selected
-	count := 0
-
-	// Scan rows and pick prices based on intervals
-	for rows.Next() {
-		var sp models.StockPrice
-		var val string
-		if err := rows.Scan(&sp.Ticker, &sp.Timestamp, &val); err != nil {
-			c.log.Error("failed to scan row", slog.String("error", err.Error()))
-			return nil, fmt.Errorf("failed to scan stock data: %w", err)
-		}
-
-		// Parse the value from string
-		value, err := strconv.Atoi(val)
-		if err != nil {
-			c.log.Error("failed to parse value", slog.String("error", err.Error()))
-			return nil, fmt.Errorf("failed to parse stock value: %w", err)
-		}
-		sp.Value = value
-
-		// Convert sp.Timestamp to int64 for comparison and assignments
-		ts := int64(sp.Timestamp)
-
-		// Pick the first row
-		if count == 0 {
-			prices = append(prices, sp)
-			lastTimestamp = ts
-			count++
-			continue
-		}
-
-		// Check if the current timestamp is within the next interval
-		if ts >= lastTimestamp+interval {
-			prices = append(prices, sp)
-			lastTimestamp = ts
-			count++
-		}
-
-		// Stop if we've picked the required number of prices
-		if count == numPrices {
-			break
-		}
+	if maxLifetime > 0 {
+		c.db.SetConnMaxLifetime(maxLifetime)
 	}
-
-	// Check for row iteration errors
-	if err := rows.Err(); err != nil {
-		c.log.Error("row iteration error", slog.String("error", err.Error()))
-		return nil, err
+	if maxIdleTime > 0 {
+		c.db.SetConnMaxIdleTime(maxIdleTime)
 	}
-
-	// Return the selected prices
-	return prices, nil
 }
 
-// GetStockPricesByTimeFrameAveraged returns averaged prices for a ticker at the specified time frame.
-func (c *Client) GetStockPricesByTimeFrameAveraged(
-	ticker string,
-	from, to time.Time,
-	numPrices int, // Number of prices to return
-) ([]models.StockPrice, error) {
-	// Sanity check for numPrices
-	if numPrices <= 0 {
-		return nil, fmt.Errorf("numPrices must be greater than 0")
-	}
-
-	// Calculate the total duration in milliseconds
-	duration := to.Sub(from).Milliseconds()
-
-	// Calculate the time interval between each price in milliseconds
-	interval := duration / int64(numPrices)
-
-	selectCols := []string{"ticker", "timestamp", "value"}
-
-	// We will select prices based on the time intervals
-	sb := c.sq.Select(selectCols...).From("tickers").
-		Where(squirrel.Eq{"ticker": ticker}).
-		Where("timestamp BETWEEN ? AND ?", from.UnixNano()/int64(time.Millisecond), to.UnixNano()/int64(time.Millisecond)).
-		OrderBy("timestamp ASC") // Order by timestamp to ensure chronological order
-
-	sqlQuery, args, err := sb.ToSql()
-	if err != nil {
-		c.log.Error("failed to build SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to build SQL query: %w", err)
-	}
-
-	rows, err := c.db.Query(sqlQuery, args...)
-	if err != nil {
-		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to query stock data: %w", err)
-	}
-	defer rows.Close()
-
-	var prices []models.StockPrice
-	var currentIntervalStart int64
-	var priceSum, priceCount int
-
-	// Iterate through the rows and group by interval
-	for rows.Next() {
-		var sp models.StockPrice
-		var val string
-		if err := rows.Scan(&sp.Ticker, &sp.Timestamp, &val); err != nil {
-			c.log.Error("failed to scan row", slog.String("error", err.Error()))
-			return nil, fmt.Errorf("failed to scan stock data: %w", err)
-		}
-
-		// Parse the value from string
-		value, err := strconv.Atoi(val)
-		if err != nil {
-			c.log.Error("failed to parse value", slog.String("error", err.Error()))
-			return nil, fmt.Errorf("failed to parse stock value: %w", err)
-		}
-		sp.Value = value
-
-		// Convert sp.Timestamp to int64 for comparisons
-		ts := int64(sp.Timestamp)
-
-		// Check if the timestamp falls within the current interval
-		if ts >= currentIntervalStart && ts < currentIntervalStart+interval {
-			priceSum += sp.Value
-			priceCount++
-		} else {
-			// If we have passed the interval, save the average for the previous interval
-			if priceCount > 0 {
-				averagePrice := priceSum / priceCount
-				prices = append(prices, models.StockPrice{
-					Ticker:    sp.Ticker,
-					Timestamp: currentIntervalStart + interval/2, // Ensure this is int64
-					Value:     averagePrice,
-				})
-			}
-
-			// Reset for the new interval
-			currentIntervalStart = ts - ts%interval
-			priceSum = sp.Value
-			priceCount = 1
-		}
-
-		// Stop if we've picked the required number of prices
-		if len(prices) == numPrices {
-			break
-		}
-	}
-
-	// Check for row iteration errors
-	if err := rows.Err(); err != nil {
-		c.log.Error("row iteration error", slog.String("error", err.Error()))
-		return nil, err
-	}
+// SetQueryTimeout bounds every query to d on top of the deadline of the
+// context passed in, 0 leaves queries bounded by the context alone. Methods
+// that run many queries, such as RebuildRollups, bound each query rather
+// than the whole call.
+func (c *Client) SetQueryTimeout(d time.Duration) {
+	c.queryTimeout = d
+}
 
-	// If there are remaining prices after looping through all rows
-	if priceCount > 0 && len(prices) < numPrices {
-		averagePrice := priceSum / priceCount
-		prices = append(prices, models.StockPrice{
-			Ticker:    "Last",
-			Timestamp: currentIntervalStart + interval/2, // Ensure this is int64
-			Value:     averagePrice,
-		})
+// withTimeout derives a context bounded by the query timeout.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, c.queryTimeout)
+}
 
-	// Return the selected prices
-	return prices, nil
+// Close closes the underlying database connection pool.
+func (c *Client) Close() error {
+	return c.db.Close()
 }
 
 // GetStockPricesByTimeFrame retrieves all prices for a ticker between the given time range.
+// When rollups are available and the range holds too many raw prices, the
+// close of every bucket of the finest fitting rollup is returned instead.
 func (c *Client) GetStockPricesByTimeFrame(
+	ctx context.Context,
 	ticker string,
 	from, to time.Time,
 ) ([]models.StockPrice, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if r, ok := c.pickRollup(from, to); ok {
+		return c.getRollupPrices(ctx, r, ticker, from, to)
+	}
+
 	selectCols := []string{"ticker", "timestamp", "value"}
 
 	sb := c.sq.Select(selectCols...).From("tickers").
@@ -325,7 +131,7 @@ func (c *Client) GetStockPricesByTimeFrame(
 		return nil, fmt.Errorf("failed to build SQL query: %w", err)
 	}
 
-	rows, err := c.db.Query(sqlQuery, args...)
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to query stock data: %w", err)
@@ -336,20 +142,11 @@ func (c *Client) GetStockPricesByTimeFrame(
 
 	for rows.Next() {
 		var sp models.StockPrice
-		var val string
-		if err := rows.Scan(&sp.Ticker, &sp.Timestamp, &val); err != nil {
+		if err := rows.Scan(&sp.Ticker, &sp.Timestamp, &sp.Value); err != nil {
 			c.log.Error("failed to scan row", slog.String("error", err.Error()))
 			return nil, fmt.Errorf("failed to scan stock data: %w", err)
 		}
 
-		// Parse the value from string
-		value, err := strconv.Atoi(val)
-		if err != nil {
-			c.log.Error("failed to parse value", slog.String("error", err.Error()))
-			return nil, fmt.Errorf("failed to parse stock value: %w", err)
-		}
-		sp.Value = value
-
 		prices = append(prices, sp)
 	}
 
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// AddDriftEvent stores a schema drift report along with the raw payload that
+// triggered it.
+func (c *Client) AddDriftEvent(ctx context.Context, d stox.Drift) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	issues, err := json.Marshal(d.Issues)
+	if err != nil {
+		return fmt.Errorf("failed to marshal issues: %w", err)
+	}
+
+	query := c.sq.Insert("drift_events").
+		Columns("endpoint", "detected_at", "signature", "issues", "payload").
+		Values(d.Endpoint, d.FetchedAt.UnixMilli(), d.Signature(), squirrel.Expr("?::jsonb", string(issues)), d.Payload)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := c.db.ExecContext(ctx, sqlQuery, args...); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("endpoint", d.Endpoint), slog.String("error", err.Error()))
+		return fmt.Errorf("failed to insert drift event: %w", err)
+	}
+
+	return nil
+}
+
+// GetLastDriftSignature returns the signature of the latest drift event of an
+// endpoint, or an empty string if there is none.
+func (c *Client) GetLastDriftSignature(ctx context.Context, endpoint string) (string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var signature string
+	err := c.db.QueryRowContext(ctx,
+		"SELECT signature FROM drift_events WHERE endpoint = $1 ORDER BY detected_at DESC, id DESC LIMIT 1",
+		endpoint,
+	).Scan(&signature)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query drift signature: %w", err)
+	}
+
+	return signature, nil
+}
+
+// GetRecentDriftEvents returns the latest drift events, newest first.
+func (c *Client) GetRecentDriftEvents(ctx context.Context, limit int) ([]models.DriftEvent, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select("id", "endpoint", "detected_at", "issues").
+		From("drift_events").
+		OrderBy("detected_at DESC", "id DESC").
+		Limit(uint64(limit))
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query drift events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.DriftEvent
+	for rows.Next() {
+		var e models.DriftEvent
+		var detectedAt int64
+		var issues []byte
+		if err := rows.Scan(&e.ID, &e.Endpoint, &detectedAt, &issues); err != nil {
+			return nil, fmt.Errorf("failed to scan drift event: %w", err)
+		}
+		if err := json.Unmarshal(issues, &e.Issues); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal drift issues: %w", err)
+		}
+		e.DetectedAt = time.UnixMilli(detectedAt)
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// EventsChannel is the NOTIFY channel the sequence number of every new event
+// is announced on.
+const EventsChannel = "events"
+
+// EventNewPrices is the type of the event AddPriceData stores for every ticker
+// with newly ingested prices, holding a models.PriceSpan of exactly the rows
+// inserted.
+const EventNewPrices = "prices.new"
+
+// eventsLockKey is the advisory lock serialising event inserts, so events
+// commit in sequence order and a reader never sees a later sequence number
+// before an earlier one.
+const eventsLockKey = 0x6576656e7473
+
+// AddEvent stores an event and notifies EventsChannel listeners of its
+// sequence number once committed.
+func (c *Client) AddEvent(ctx context.Context, typ string, at time.Time, payload []byte) (int64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	seq, err := c.insertEvent(ctx, tx, typ, at, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return seq, nil
+}
+
+// insertEvent stores an event in tx, which notifies EventsChannel listeners
+// of its sequence number when it commits. The events lock is held until then,
+// so events should be inserted last in a transaction.
+func (c *Client) insertEvent(ctx context.Context, tx *sql.Tx, typ string, at time.Time, payload []byte) (int64, error) {
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", eventsLockKey); err != nil {
+		return 0, fmt.Errorf("failed to lock events: %w", err)
+	}
+
+	query := c.sq.Insert("events").
+		Columns("type", "created_at", "payload").
+		Values(typ, at.UnixMilli(), squirrel.Expr("?::jsonb", string(payload))).
+		Suffix("RETURNING seq")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx, sqlQuery, args...).Scan(&seq); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("type", typ), slog.String("error", err.Error()))
+		return 0, fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", EventsChannel, fmt.Sprint(seq)); err != nil {
+		return 0, fmt.Errorf("failed to notify event: %w", err)
+	}
+
+	return seq, nil
+}
+
+// GetEventsAfter returns up to limit events with a sequence number greater
+// than seq, in sequence order.
+func (c *Client) GetEventsAfter(ctx context.Context, seq int64, limit int) ([]models.Event, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select("seq", "type", "created_at", "payload").
+		From("events").
+		Where(squirrel.Gt{"seq": seq}).
+		OrderBy("seq").
+		Limit(uint64(limit))
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var e models.Event
+		var createdAt int64
+		var payload []byte
+		if err := rows.Scan(&e.Seq, &e.Type, &createdAt, &payload); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		e.CreatedAt = time.UnixMilli(createdAt)
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetLatestEventSeq returns the sequence number of the newest event, or 0 if
+// there are none.
+func (c *Client) GetLatestEventSeq(ctx context.Context) (int64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var seq int64
+	if err := c.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(seq), 0) FROM events").Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to query latest event: %w", err)
+	}
+
+	return seq, nil
+}
+
+// DeleteEventsBefore removes events created before a point in time and
+// returns how many were deleted.
+func (c *Client) DeleteEventsBefore(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	res, err := c.db.ExecContext(ctx, "DELETE FROM events WHERE created_at < $1", before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete events: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// insertNewPricesEvents stores an EventNewPrices event in tx for every ticker
+// of prices, which must be ordered by ticker and timestamp, so subscribers
+// hear of the prices exactly when they are committed.
+func (c *Client) insertNewPricesEvents(ctx context.Context, tx *sql.Tx, at time.Time, prices []models.StockPrice) error {
+	for len(prices) > 0 {
+		n := 1
+		for n < len(prices) && prices[n].Ticker == prices[0].Ticker {
+			n++
+		}
+
+		payload, err := json.Marshal(models.PriceSpan{
+			Ticker: prices[0].Ticker,
+			From:   prices[0].Timestamp,
+			To:     prices[n-1].Timestamp,
+			Count:  n,
+			Prices: prices[:n],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal new prices event: %w", err)
+		}
+
+		if _, err := c.insertEvent(ctx, tx, EventNewPrices, at, payload); err != nil {
+			return err
+		}
+
+		prices = prices[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// GetTickers returns every ticker that has price data.
+func (c *Client) GetTickers(ctx context.Context) ([]string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, "SELECT DISTINCT ticker FROM tickers ORDER BY ticker")
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query tickers: %w", err)
+	}
+	defer rows.Close()
+
+	var tickers []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan ticker: %w", err)
+		}
+		tickers = append(tickers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickers, nil
+}
+
+// FindGaps returns the periods between from and to where a ticker has no
+// samples for longer than maxGap, oldest first. The window bounds count as
+// edges, so the time before the first and after the last price in it can be
+// gaps, and the whole window is one when it has no prices.
+func (c *Client) FindGaps(
+	ctx context.Context,
+	ticker string,
+	from, to time.Time,
+	maxGap time.Duration,
+) ([]models.PriceGap, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		SELECT prev_timestamp, timestamp FROM (
+			SELECT timestamp, LAG(timestamp, 1, $2::BIGINT) OVER (ORDER BY timestamp) AS prev_timestamp
+			FROM (
+				SELECT timestamp FROM tickers
+				WHERE ticker = $1 AND timestamp BETWEEN $2 AND $3
+				UNION ALL
+				SELECT $3::BIGINT
+			) w
+		) t
+		WHERE timestamp - prev_timestamp > $4
+		ORDER BY timestamp`
+
+	rows, err := c.db.QueryContext(ctx, query, ticker, from.UnixMilli(), to.UnixMilli(), maxGap.Milliseconds())
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var gaps []models.PriceGap
+	for rows.Next() {
+		g := models.PriceGap{Ticker: ticker}
+		if err := rows.Scan(&g.From, &g.To); err != nil {
+			return nil, fmt.Errorf("failed to scan gap: %w", err)
+		}
+		gaps = append(gaps, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gaps, nil
+}
+
+// MarkUnrecoverableGaps records gaps a backfill could not fill in the
+// unrecoverable_gaps table, and removes the ones ending before before, in a
+// single transaction.
+func (c *Client) MarkUnrecoverableGaps(ctx context.Context, gaps []models.PriceGap, before time.Time) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM unrecoverable_gaps WHERE gap_end < $1", before.UnixMilli()); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to delete unrecoverable gaps: %w", err)
+	}
+
+	if len(gaps) > 0 {
+		query := c.sq.Insert("unrecoverable_gaps").
+			Columns("ticker", "gap_start", "gap_end").
+			Suffix("ON CONFLICT DO NOTHING")
+		for _, g := range gaps {
+			query = query.Values(g.Ticker, g.From, g.To)
+		}
+
+		sqlQuery, args, err := query.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build SQL query: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, sqlQuery, args...); err != nil {
+			c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+			return fmt.Errorf("failed to insert unrecoverable gaps: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetUnrecoverableGaps returns the recorded unrecoverable gaps ending at or
+// after from.
+func (c *Client) GetUnrecoverableGaps(ctx context.Context, from time.Time) ([]models.PriceGap, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT ticker, gap_start, gap_end FROM unrecoverable_gaps WHERE gap_end >= $1 ORDER BY ticker, gap_start",
+		from.UnixMilli())
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query unrecoverable gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var gaps []models.PriceGap
+	for rows.Next() {
+		var g models.PriceGap
+		if err := rows.Scan(&g.Ticker, &g.From, &g.To); err != nil {
+			return nil, fmt.Errorf("failed to scan unrecoverable gap: %w", err)
+		}
+		gaps = append(gaps, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return gaps, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// ingestBatchSize is the number of rows per multi-row insert, well under the
+// 65535 bind parameter limit of postgres.
+const ingestBatchSize = 1000
+
+// IngestResult reports the outcome of a bulk ingest.
+type IngestResult struct {
+	// Inserted is the number of new rows written.
+	Inserted int
+	// Skipped is the number of rows that already existed or were invalid.
+	Skipped int
+	// Prices are the newly written rows, ordered by ticker and timestamp.
+	Prices []models.StockPrice
+}
+
+type priceRow struct {
+	ticker    string
+	timestamp int64
+	value     int
+}
+
+// AddPriceData writes a whole stox.PriceData payload into the tickers table in
+// a single transaction using multi-row inserts, along with an EventNewPrices
+// event for every ticker with new prices, catalog entries for tickers new to
+// it and, with MaintainRollups, the refreshed rollups around the prices. Rows that already exist are skipped,
+// as are rows with a timestamp that is not a valid integer and rows older than
+// the retention cutoff.
+func (c *Client) AddPriceData(ctx context.Context, data stox.PriceData) (IngestResult, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var result IngestResult
+
+	cutoff, err := getRawCutoff(ctx, c.db)
+	if err != nil {
+		return IngestResult{}, err
+	}
+
+	rows := make([]priceRow, 0)
+	for ticker, timeseries := range data.Prices {
+		for ts, price := range timeseries {
+			timestamp, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				c.log.Warn("skipping price with invalid timestamp", slog.String("ticker", ticker), slog.String("timestamp", ts))
+				result.Skipped++
+				continue
+			}
+			if timestamp < cutoff {
+				result.Skipped++
+				continue
+			}
+			rows = append(rows, priceRow{ticker: ticker, timestamp: timestamp, value: price})
+		}
+	}
+
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	// Insert in a stable order so concurrent ingests lock rows in the same order.
+	slices.SortFunc(rows, func(a, b priceRow) int {
+		return cmp.Or(strings.Compare(a.ticker, b.ticker), cmp.Compare(a.timestamp, b.timestamp))
+	})
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return IngestResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for batch := range slices.Chunk(rows, ingestBatchSize) {
+		query := c.sq.Insert("tickers").
+			Columns("ticker", "timestamp", "value").
+			Suffix("ON CONFLICT (ticker, timestamp) DO NOTHING RETURNING ticker, timestamp, value")
+		for _, r := range batch {
+			query = query.Values(r.ticker, r.timestamp, r.value)
+		}
+
+		sqlQuery, args, err := query.ToSql()
+		if err != nil {
+			return IngestResult{}, fmt.Errorf("failed to build SQL query: %w", err)
+		}
+
+		batchInserted, err := queryPrices(ctx, tx, sqlQuery, args)
+		if err != nil {
+			return IngestResult{}, fmt.Errorf("failed to insert stock data: %w", err)
+		}
+
+		result.Prices = append(result.Prices, batchInserted...)
+		result.Inserted += len(batchInserted)
+		result.Skipped += len(batch) - len(batchInserted)
+	}
+
+	// RETURNING does not guarantee the order of the VALUES list.
+	slices.SortFunc(result.Prices, func(a, b models.StockPrice) int {
+		return cmp.Or(strings.Compare(a.Ticker, b.Ticker), cmp.Compare(a.Timestamp, b.Timestamp))
+	})
+
+	if err := c.insertCatalogTickers(ctx, tx, c.now(), result.Prices); err != nil {
+		return IngestResult{}, err
+	}
+
+	if c.maintainRollups {
+		if err := c.refreshNewRollups(ctx, tx, result.Prices); err != nil {
+			return IngestResult{}, err
+		}
+	}
+
+	if err := c.insertNewPricesEvents(ctx, tx, c.now(), result.Prices); err != nil {
+		return IngestResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return IngestResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	c.log.Info("ingested price data", slog.Int("inserted", result.Inserted), slog.Int("skipped", result.Skipped))
+	return result, nil
+}
+
+// queryPrices runs a query returning ticker, timestamp and value rows.
+func queryPrices(ctx context.Context, tx *sql.Tx, query string, args []any) ([]models.StockPrice, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.StockPrice
+	for rows.Next() {
+		var p models.StockPrice
+		if err := rows.Scan(&p.Ticker, &p.Timestamp, &p.Value); err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+
+	return prices, rows.Err()
+}
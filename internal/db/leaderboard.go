@@ -0,0 +1,219 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// LeaderboardResult reports the outcome of recording a leaderboard.
+type LeaderboardResult struct {
+	Users int
+	Clans int
+}
+
+// AddLeaderboard records a leaderboard taken at the given time. Profiles and
+// clans are upserted so every user and clan is stored once with its latest
+// details, and every user gets a portfolio_values row with their rank.
+func (c *Client) AddLeaderboard(ctx context.Context, at time.Time, values []stox.PortfolioValue) (LeaderboardResult, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var result LeaderboardResult
+	if len(values) == 0 {
+		return result, nil
+	}
+
+	ts := at.UnixMilli()
+
+	// Rank by portfolio value, keeping the upstream order for ties, and drop
+	// duplicate entries for the same user.
+	ranked := slices.Clone(values)
+	slices.SortStableFunc(ranked, func(a, b stox.PortfolioValue) int {
+		return cmp.Compare(b.PortfolioValue, a.PortfolioValue)
+	})
+
+	seenUsers := map[string]bool{}
+	clans := map[string]stox.Clan{}
+	users := make([]stox.PortfolioValue, 0, len(ranked))
+	for _, v := range ranked {
+		if v.UserID == "" || seenUsers[v.UserID] {
+			continue
+		}
+		seenUsers[v.UserID] = true
+		users = append(users, v)
+
+		if tag := v.Profile.Clan.Tag; tag != "" {
+			clans[tag] = v.Profile.Clan
+		}
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(clans) > 0 {
+		query := c.sq.Insert("clans").
+			Columns("tag", "rank", "color", "emblem", "first_seen", "last_seen").
+			Suffix(`ON CONFLICT (tag) DO UPDATE SET
+				rank = EXCLUDED.rank,
+				color = EXCLUDED.color,
+				emblem = EXCLUDED.emblem,
+				last_seen = EXCLUDED.last_seen`)
+		for _, clan := range clans {
+			query = query.Values(clan.Tag, clan.Rank, clan.Color, clan.Emblem, ts, ts)
+		}
+
+		if err := execTx(ctx, tx, query); err != nil {
+			return LeaderboardResult{}, fmt.Errorf("failed to upsert clans: %w", err)
+		}
+	}
+
+	for start := 0; start < len(users); start += ingestBatchSize {
+		batch := users[start:min(start+ingestBatchSize, len(users))]
+		if err := c.addLeaderboardBatch(ctx, tx, ts, start+1, batch); err != nil {
+			return LeaderboardResult{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return LeaderboardResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result = LeaderboardResult{
+		Users: len(users),
+		Clans: len(clans),
+	}
+
+	c.log.Info("recorded leaderboard", slog.Int("users", result.Users), slog.Int("clans", result.Clans))
+	return result, nil
+}
+
+// addLeaderboardBatch upserts the profiles and inserts the portfolio values of
+// a batch of ranked users, the first of which has the given rank.
+func (c *Client) addLeaderboardBatch(
+	ctx context.Context,
+	tx *sql.Tx,
+	ts int64,
+	firstRank int,
+	users []stox.PortfolioValue,
+) error {
+	userQuery := c.sq.Insert("users").
+		Columns("id", "display_name", "color", "photo", "xp", "clan_tag", "joined",
+			"medals", "season_pass", "bio", "first_seen", "last_seen").
+		Suffix(`ON CONFLICT (id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			color = EXCLUDED.color,
+			photo = EXCLUDED.photo,
+			xp = EXCLUDED.xp,
+			clan_tag = EXCLUDED.clan_tag,
+			joined = EXCLUDED.joined,
+			medals = EXCLUDED.medals,
+			season_pass = EXCLUDED.season_pass,
+			bio = EXCLUDED.bio,
+			last_seen = EXCLUDED.last_seen`)
+	valueQuery := c.sq.Insert("portfolio_values").
+		Columns("user_id", "timestamp", "value", "rank").
+		Suffix("ON CONFLICT (user_id, timestamp) DO NOTHING")
+
+	for i, v := range users {
+		p := v.Profile
+
+		var clanTag *string
+		if p.Clan.Tag != "" {
+			clanTag = &p.Clan.Tag
+		}
+
+		medals := []byte("{}")
+		if p.Medals != nil {
+			var err error
+			medals, err = json.Marshal(p.Medals)
+			if err != nil {
+				return fmt.Errorf("failed to marshal medals: %w", err)
+			}
+		}
+
+		userQuery = userQuery.Values(v.UserID, p.DisplayName, p.Color, p.Photo, p.XP, clanTag, p.Joined,
+			squirrel.Expr("?::jsonb", string(medals)), p.SeasonPass, p.Bio, ts, ts)
+		valueQuery = valueQuery.Values(v.UserID, ts, v.PortfolioValue, firstRank+i)
+	}
+
+	if err := execTx(ctx, tx, userQuery); err != nil {
+		return fmt.Errorf("failed to upsert users: %w", err)
+	}
+
+	if err := execTx(ctx, tx, valueQuery); err != nil {
+		return fmt.Errorf("failed to insert portfolio values: %w", err)
+	}
+
+	return nil
+}
+
+// GetPortfolioHistory returns a user's portfolio value and rank between the
+// given time range, oldest first.
+func (c *Client) GetPortfolioHistory(
+	ctx context.Context,
+	userID string,
+	from, to time.Time,
+) ([]models.PortfolioValue, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select("user_id", "timestamp", "value", "rank").From("portfolio_values").
+		Where(squirrel.Eq{"user_id": userID}).
+		Where("timestamp BETWEEN ? AND ?", from.UnixMilli(), to.UnixMilli()).
+		OrderBy("timestamp ASC")
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("userID", userID), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query portfolio values: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.PortfolioValue
+	for rows.Next() {
+		var pv models.PortfolioValue
+		if err := rows.Scan(&pv.UserID, &pv.Timestamp, &pv.Value, &pv.Rank); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio value: %w", err)
+		}
+		history = append(history, pv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// execTx builds and executes an insert inside the given transaction.
+func execTx(ctx context.Context, tx *sql.Tx, query squirrel.InsertBuilder) error {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlQuery, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
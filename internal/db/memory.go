@@ -0,0 +1,417 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// MemoryStore is an in-memory PriceStore and ScrapeStore with the same
+// semantics as Client, for running the handlers without Postgres.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	prices  map[string]map[int64]models.Price
+	catalog map[string]models.TickerInfo
+	runs    []models.ScrapeRun
+	drift   []models.DriftEvent
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		prices:  map[string]map[int64]models.Price{},
+		catalog: map[string]models.TickerInfo{},
+	}
+}
+
+func (m *MemoryStore) AddPriceData(ctx context.Context, data stox.PriceData) (IngestResult, error) {
+	if err := ctx.Err(); err != nil {
+		return IngestResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result IngestResult
+	for ticker, timeseries := range data.Prices {
+		for ts, price := range timeseries {
+			timestamp, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+
+			if _, ok := m.prices[ticker][timestamp]; ok {
+				result.Skipped++
+				continue
+			}
+
+			if m.prices[ticker] == nil {
+				m.prices[ticker] = map[int64]models.Price{}
+			}
+			m.prices[ticker][timestamp] = models.PriceFromInt(price)
+			result.Prices = append(result.Prices, models.StockPrice{Ticker: ticker, Timestamp: timestamp, Value: models.PriceFromInt(price)})
+		}
+	}
+
+	slices.SortFunc(result.Prices, func(a, b models.StockPrice) int {
+		return cmp.Or(strings.Compare(a.Ticker, b.Ticker), cmp.Compare(a.Timestamp, b.Timestamp))
+	})
+	result.Inserted = len(result.Prices)
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	for _, p := range result.Prices {
+		if _, ok := m.catalog[p.Ticker]; !ok {
+			m.catalog[p.Ticker] = models.TickerInfo{Ticker: p.Ticker, FirstSeen: now, LastSeen: now}
+		}
+	}
+
+	return result, nil
+}
+
+func (m *MemoryStore) GetStockPricesByTimeFrame(ctx context.Context, ticker string, from, to time.Time) ([]models.StockPrice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.pricesBetween(ticker, from.UnixMilli(), to.UnixMilli()), nil
+}
+
+// pricesBetween returns the prices of a ticker in [from, to], oldest first.
+// The caller must hold the lock.
+func (m *MemoryStore) pricesBetween(ticker string, from, to int64) []models.StockPrice {
+	var prices []models.StockPrice
+	for ts, value := range m.prices[ticker] {
+		if ts >= from && ts <= to {
+			prices = append(prices, models.StockPrice{Ticker: ticker, Timestamp: ts, Value: value})
+		}
+	}
+
+	slices.SortFunc(prices, func(a, b models.StockPrice) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	return prices
+}
+
+func (m *MemoryStore) GetPriceBuckets(ctx context.Context, ticker string, opts prices.Options) ([]models.PriceBucket, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return prices.Aggregate(m.pricesBetween(ticker, opts.Range.From.UnixMilli(), opts.Range.To.UnixMilli()), opts)
+}
+
+func (m *MemoryStore) GetTickers(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tickers []string
+	for ticker, prices := range m.prices {
+		if len(prices) > 0 {
+			tickers = append(tickers, ticker)
+		}
+	}
+	slices.Sort(tickers)
+
+	return tickers, nil
+}
+
+func (m *MemoryStore) GetPriceRange(ctx context.Context, ticker string) (from, to time.Time, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var first, last int64
+	for ts := range m.prices[ticker] {
+		if !ok || ts < first {
+			first = ts
+		}
+		if !ok || ts > last {
+			last = ts
+		}
+		ok = true
+	}
+
+	if !ok {
+		return time.Time{}, time.Time{}, false, nil
+	}
+
+	return time.UnixMilli(first), time.UnixMilli(last), true, nil
+}
+
+func (m *MemoryStore) FindGaps(ctx context.Context, ticker string, from, to time.Time, maxGap time.Duration) ([]models.PriceGap, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// The window bounds are edges like prices are.
+	edges := []int64{from.UnixMilli()}
+	for _, p := range m.pricesBetween(ticker, from.UnixMilli(), to.UnixMilli()) {
+		edges = append(edges, p.Timestamp)
+	}
+	edges = append(edges, to.UnixMilli())
+
+	var gaps []models.PriceGap
+	for i := 1; i < len(edges); i++ {
+		if edges[i]-edges[i-1] > maxGap.Milliseconds() {
+			gaps = append(gaps, models.PriceGap{Ticker: ticker, From: edges[i-1], To: edges[i]})
+		}
+	}
+
+	return gaps, nil
+}
+
+func (m *MemoryStore) UpdateCatalog(ctx context.Context, at time.Time, stocks []stox.Stock) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(stocks) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Timestamps are stored with millisecond precision, like in Postgres.
+	at = time.UnixMilli(at.UnixMilli())
+	for _, s := range stocks {
+		info, ok := m.catalog[s.TickerSymbol]
+		if !ok {
+			info = models.TickerInfo{Ticker: s.TickerSymbol, FirstSeen: at}
+		}
+		info.LastSeen = at
+		info.IpoPrice = models.PriceFromInt(s.IpoPrice)
+		info.TotalShares = s.TotalShares
+		info.Delisted = false
+		m.catalog[s.TickerSymbol] = info
+	}
+
+	for ticker, info := range m.catalog {
+		if info.LastSeen.Before(at) {
+			info.Delisted = true
+			m.catalog[ticker] = info
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) ListTickers(ctx context.Context, includeDelisted bool) ([]models.TickerInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tickers []models.TickerInfo
+	for _, info := range m.catalog {
+		if includeDelisted || !info.Delisted {
+			tickers = append(tickers, info)
+		}
+	}
+
+	slices.SortFunc(tickers, func(a, b models.TickerInfo) int {
+		return strings.Compare(a.Ticker, b.Ticker)
+	})
+	return tickers, nil
+}
+
+func (m *MemoryStore) SearchTickers(ctx context.Context, query string, limit int) ([]models.TickerInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	query = strings.ToLower(query)
+
+	var tickers []models.TickerInfo
+	for _, info := range m.catalog {
+		if strings.Contains(strings.ToLower(info.Ticker), query) {
+			tickers = append(tickers, info)
+		}
+	}
+
+	isPrefix := func(t models.TickerInfo) bool {
+		return strings.HasPrefix(strings.ToLower(t.Ticker), query)
+	}
+	slices.SortFunc(tickers, func(a, b models.TickerInfo) int {
+		return cmp.Or(
+			-compareBool(isPrefix(a), isPrefix(b)),
+			compareBool(a.Delisted, b.Delisted),
+			strings.Compare(a.Ticker, b.Ticker),
+		)
+	})
+
+	if len(tickers) > limit {
+		tickers = tickers[:limit]
+	}
+	return tickers, nil
+}
+
+func (m *MemoryStore) GetTicker(ctx context.Context, ticker string) (info models.TickerInfo, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return info, false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	info, ok = m.catalog[ticker]
+	return info, ok, nil
+}
+
+func (m *MemoryStore) AddScrapeRun(ctx context.Context, run models.ScrapeRun) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run.ID = int64(len(m.runs) + 1)
+	run.StartedAt = time.UnixMilli(run.StartedAt.UnixMilli())
+	run.FinishedAt = time.UnixMilli(run.FinishedAt.UnixMilli())
+	run.UpstreamLatency = run.UpstreamLatency.Truncate(time.Millisecond)
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func (m *MemoryStore) GetRecentScrapeRuns(ctx context.Context, limit int) ([]models.ScrapeRun, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	runs := slices.Clone(m.runs)
+	slices.SortFunc(runs, func(a, b models.ScrapeRun) int {
+		return cmp.Or(b.StartedAt.Compare(a.StartedAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (m *MemoryStore) GetScrapeFailureStreaks(ctx context.Context) ([]models.ScrapeFailureStreak, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lastSuccess := map[string]time.Time{}
+	for _, r := range m.runs {
+		if r.Status == models.ScrapeRunSuccess && r.StartedAt.After(lastSuccess[r.Job]) {
+			lastSuccess[r.Job] = r.StartedAt
+		}
+	}
+
+	byJob := map[string]*models.ScrapeFailureStreak{}
+	lastFailure := map[string]time.Time{}
+	for _, r := range m.runs {
+		if r.Status != models.ScrapeRunFailed || !r.StartedAt.After(lastSuccess[r.Job]) {
+			continue
+		}
+
+		s, ok := byJob[r.Job]
+		if !ok {
+			s = &models.ScrapeFailureStreak{Job: r.Job, Since: r.StartedAt}
+			byJob[r.Job] = s
+		}
+		s.Failures++
+		if r.StartedAt.Before(s.Since) {
+			s.Since = r.StartedAt
+		}
+		if !r.StartedAt.Before(lastFailure[r.Job]) {
+			lastFailure[r.Job] = r.StartedAt
+			s.LastError = r.Error
+		}
+	}
+
+	var streaks []models.ScrapeFailureStreak
+	for _, s := range byJob {
+		streaks = append(streaks, *s)
+	}
+	slices.SortFunc(streaks, func(a, b models.ScrapeFailureStreak) int {
+		return strings.Compare(a.Job, b.Job)
+	})
+	return streaks, nil
+}
+
+func (m *MemoryStore) AddDriftEvent(ctx context.Context, d stox.Drift) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.drift = append(m.drift, models.DriftEvent{
+		ID:         int64(len(m.drift) + 1),
+		Endpoint:   d.Endpoint,
+		DetectedAt: time.UnixMilli(d.FetchedAt.UnixMilli()),
+		Issues:     slices.Clone(d.Issues),
+	})
+	return nil
+}
+
+func (m *MemoryStore) GetRecentDriftEvents(ctx context.Context, limit int) ([]models.DriftEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := slices.Clone(m.drift)
+	slices.SortFunc(events, func(a, b models.DriftEvent) int {
+		return cmp.Or(b.DetectedAt.Compare(a.DetectedAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,310 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/goyesql"
+)
+
+// sqlFiles holds schema.sql, the schema of a fresh database, and the
+// migrations/<version>.sql files bringing existing databases up to it. Every
+// new table goes in both.
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+// MigrationsDir is where new migrations are created, relative to the
+// repository root.
+const MigrationsDir = "internal/db/sql/migrations"
+
+// migrationsLockKey is the advisory lock serialising migrations, so binaries
+// starting together do not apply the same migration twice.
+const migrationsLockKey = 0x6d696772617465
+
+// Migration is a versioned schema change, with the SQL applying and reverting
+// it.
+type Migration struct {
+	Version int
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is the schema version of the database along with every
+// known migration.
+type MigrationStatus struct {
+	// Initialised is false until the schema has been created.
+	Initialised bool
+	Version     int
+	Migrations  []Migration
+}
+
+// Pending returns the migrations newer than the database schema.
+func (s MigrationStatus) Pending() []Migration {
+	var pending []Migration
+	for _, m := range s.Migrations {
+		if !s.Initialised || m.Version > s.Version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Migrations returns the embedded migrations ordered by version. Each file
+// has an up and a down section, named with goyesql tags.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(sqlFiles, "sql/migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".sql")
+		if e.IsDir() || !ok {
+			continue
+		}
+
+		version, err := strconv.Atoi(name)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration file name %s, expected <version>.sql", e.Name())
+		}
+
+		queries, err := parseSQLFile(path.Join("sql/migrations", e.Name()), "up", "down")
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Up:      queries["up"].Query,
+			Down:    queries["down"].Query,
+		})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+	return migrations, nil
+}
+
+// parseSQLFile parses an embedded goyesql file, checking it has every named
+// query.
+func parseSQLFile(name string, required ...string) (goyesql.Queries, error) {
+	b, err := sqlFiles.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	queries, err := goyesql.ParseBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	for _, r := range required {
+		if _, ok := queries[r]; !ok {
+			return nil, fmt.Errorf("%s has no %s query", name, r)
+		}
+	}
+
+	return queries, nil
+}
+
+// MigrationStatus returns the schema version of the database and the known
+// migrations.
+func (c *Client) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{Migrations: migrations}
+	status.Initialised, status.Version, err = getSchemaVersion(ctx, c.db)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	return status, nil
+}
+
+// MigrateUp brings the schema up to date in a single transaction and returns
+// the versions applied. A fresh database gets schema.sql and is marked as
+// being at the latest version rather than replaying every migration.
+//
+// Migrations are not bound by the query timeout as they can rewrite whole
+// tables.
+func (c *Client) MigrateUp(ctx context.Context) ([]int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+
+	tx, err := c.beginMigration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	initialised, version, err := getSchemaVersion(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	if !initialised {
+		schema, err := parseSQLFile("sql/schema.sql", "schema_up")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, schema["schema_up"].Query); err != nil {
+			return nil, fmt.Errorf("failed to apply schema: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS migrations (
+				id SERIAL PRIMARY KEY,
+				version INTEGER NOT NULL
+			)`); err != nil {
+			return nil, fmt.Errorf("failed to create migrations table: %w", err)
+		}
+
+		version = latest
+		c.log.Info("Initialised schema", "version", version)
+	} else {
+		for _, m := range migrations {
+			if m.Version <= version {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return nil, fmt.Errorf("failed to apply migration %d: %w", m.Version, err)
+			}
+
+			version = m.Version
+			applied = append(applied, m.Version)
+			c.log.Info("Applied migration", "version", m.Version)
+		}
+	}
+
+	if err := setSchemaVersion(ctx, tx, version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations in a single
+// transaction and returns the versions reverted, newest first.
+func (c *Client) MigrateDown(ctx context.Context, steps int) ([]int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.beginMigration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	initialised, version, err := getSchemaVersion(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !initialised {
+		return nil, fmt.Errorf("schema is not initialised")
+	}
+
+	var reverted []int
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := migrations[i]
+		if m.Version > version {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return nil, fmt.Errorf("failed to revert migration %d: %w", m.Version, err)
+		}
+
+		version = 0
+		if i > 0 {
+			version = migrations[i-1].Version
+		}
+		reverted = append(reverted, m.Version)
+		c.log.Info("Reverted migration", "version", m.Version)
+	}
+
+	if err := setSchemaVersion(ctx, tx, version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return reverted, nil
+}
+
+// beginMigration starts a transaction holding the migrations lock.
+func (c *Client) beginMigration(ctx context.Context) (*sql.Tx, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationsLockKey); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	return tx, nil
+}
+
+// getSchemaVersion reads the version from the migrations table, reporting
+// initialised false when the table does not exist yet.
+func getSchemaVersion(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) (initialised bool, version int, err error) {
+	err = q.QueryRowContext(ctx, "SELECT to_regclass('migrations') IS NOT NULL").Scan(&initialised)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+	if !initialised {
+		return false, 0, nil
+	}
+
+	err = q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM migrations WHERE id = 1").Scan(&version)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to query schema version: %w", err)
+	}
+
+	return true, version, nil
+}
+
+func setSchemaVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO migrations (id, version)
+		VALUES (1, $1)
+		ON CONFLICT (id)
+		DO UPDATE SET version = EXCLUDED.version`, version); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listen subscribes to a NOTIFY channel on a dedicated connection and calls fn
+// with the payload of every notification until ctx is cancelled. The
+// connection is re-established on its own if it drops, after which fn is
+// called with an empty payload since notifications sent while it was down are
+// lost.
+func (c *Client) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	listener := pq.NewListener(c.connUrl, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			c.log.Warn("notify listener disconnected", slog.String("channel", channel), slog.Any("error", err))
+		case pq.ListenerEventReconnected:
+			c.log.Info("notify listener reconnected", slog.String("channel", channel))
+		case pq.ListenerEventConnectionAttemptFailed:
+			c.log.Warn("notify listener failed to connect", slog.String("channel", channel), slog.Any("error", err))
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				fn("")
+				continue
+			}
+			fn(n.Extra)
+		case <-ping.C:
+			// Pinging surfaces dead connections that would otherwise go
+			// unnoticed until the next notification.
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RetentionReport is the outcome of a retention run, or what it would do on a
+// dry run.
+type RetentionReport struct {
+	// Cutoff is the point before which raw prices are removed.
+	Cutoff  time.Time
+	DryRun  bool
+	Tickers []TickerRetention
+}
+
+// TickerRetention counts the rows of a ticker older than the cutoff.
+type TickerRetention struct {
+	Ticker string
+	// RawRows is the number of raw prices removed.
+	RawRows int
+	// RollupRows is the number of rollup buckets finer than the kept
+	// resolution removed.
+	RollupRows int
+	// DaysDownsampled is the number of days rolled up before removal.
+	DaysDownsampled int
+}
+
+// getRawCutoff returns the time before which raw prices have been removed,
+// or zero if retention never ran.
+func getRawCutoff(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) (int64, error) {
+	var cutoff int64
+	err := q.QueryRowContext(ctx,
+		"SELECT cutoff FROM retention_cutoffs WHERE table_name = 'tickers'",
+	).Scan(&cutoff)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query retention cutoff: %w", err)
+	}
+
+	return cutoff, nil
+}
+
+// ApplyRetention removes raw prices older than keepRaw, first rolling every
+// affected day up so its OHLC survives at keepWidth and coarser. Rollups finer
+// than keepWidth are removed along with the raw prices. The cutoff is aligned
+// to the start of a UTC day so whole rollup buckets are either kept or
+// removed. Once removed, older prices are no longer ingested and rollups are
+// never refreshed before the cutoff, so the downsampled history is not
+// overwritten from partial data. With dryRun nothing is changed and the
+// report holds the rows that would be removed.
+func (c *Client) ApplyRetention(ctx context.Context, keepRaw, keepWidth time.Duration, dryRun bool) (RetentionReport, error) {
+	cutoff := c.now().Add(-keepRaw).Truncate(24 * time.Hour)
+	report := RetentionReport{Cutoff: cutoff, DryRun: dryRun}
+
+	tickers, err := c.GetTickers(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, ticker := range tickers {
+		tr, err := c.countRetention(ctx, ticker, cutoff.UnixMilli(), keepWidth)
+		if err != nil {
+			return report, err
+		}
+
+		if tr.RawRows == 0 && tr.RollupRows == 0 {
+			continue
+		}
+
+		if !dryRun && tr.RawRows > 0 {
+			first, _, _, err := c.GetPriceRange(ctx, ticker)
+			if err != nil {
+				return report, err
+			}
+
+			tr.DaysDownsampled, err = c.RebuildRollups(ctx, ticker, first, cutoff)
+			if err != nil {
+				return report, fmt.Errorf("failed to downsample %s: %w", ticker, err)
+			}
+		}
+
+		report.Tickers = append(report.Tickers, tr)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if err := c.removeBefore(ctx, cutoff.UnixMilli(), keepWidth); err != nil {
+		return report, err
+	}
+
+	c.log.Info("applied retention", slog.Time("cutoff", cutoff), slog.Int("tickers", len(report.Tickers)))
+	return report, nil
+}
+
+// countRetention counts the raw prices and rollup buckets finer than keepWidth
+// of a ticker older than cutoff.
+func (c *Client) countRetention(ctx context.Context, ticker string, cutoff int64, keepWidth time.Duration) (TickerRetention, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	tr := TickerRetention{Ticker: ticker}
+
+	err := c.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM tickers WHERE ticker = $1 AND timestamp < $2",
+		ticker, cutoff,
+	).Scan(&tr.RawRows)
+	if err != nil {
+		return tr, fmt.Errorf("failed to count prices of %s: %w", ticker, err)
+	}
+
+	for _, r := range tableRollups {
+		if r.width >= keepWidth {
+			continue
+		}
+
+		var n int
+		err := c.db.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ticker = $1 AND bucket < $2", r.table),
+			ticker, cutoff,
+		).Scan(&n)
+		if err != nil {
+			return tr, fmt.Errorf("failed to count %s of %s: %w", r.table, ticker, err)
+		}
+		tr.RollupRows += n
+	}
+
+	return tr, nil
+}
+
+// retentionBatch is the most rows removed per statement, so a large backlog
+// is removed in many short deletes instead of one that outlives the query
+// timeout.
+const retentionBatch = 10000
+
+// removeBefore moves the raw cutoff forward and removes raw prices and fine
+// rollups older than it in batches. The cutoff is stored first, so a run that
+// fails part way leaves nothing older visible and the next run carries on
+// deleting.
+func (c *Client) removeBefore(ctx context.Context, cutoff int64, keepWidth time.Duration) error {
+	if err := c.storeRawCutoff(ctx, cutoff); err != nil {
+		return err
+	}
+
+	if err := c.deleteBatched(ctx, "tickers", "timestamp", cutoff); err != nil {
+		return fmt.Errorf("failed to delete prices: %w", err)
+	}
+
+	for _, r := range tableRollups {
+		if r.width >= keepWidth {
+			continue
+		}
+
+		if err := c.deleteBatched(ctx, r.table, "bucket", cutoff); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", r.table, err)
+		}
+	}
+
+	return nil
+}
+
+// storeRawCutoff moves the raw cutoff forward to cutoff, never back.
+func (c *Client) storeRawCutoff(ctx context.Context, cutoff int64) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `INSERT INTO retention_cutoffs (table_name, cutoff) VALUES ('tickers', $1)
+		ON CONFLICT (table_name) DO UPDATE SET cutoff = GREATEST(retention_cutoffs.cutoff, EXCLUDED.cutoff)`,
+		cutoff,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store retention cutoff: %w", err)
+	}
+
+	return nil
+}
+
+// deleteBatched removes the rows of table with column older than cutoff,
+// retentionBatch rows at a time, each batch under its own query timeout.
+func (c *Client) deleteBatched(ctx context.Context, table, column string, cutoff int64) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[2]s < $1 LIMIT %[3]d)",
+		table, column, retentionBatch,
+	)
+
+	for {
+		n, err := c.deleteBatch(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+		if n < retentionBatch {
+			return nil
+		}
+	}
+}
+
+func (c *Client) deleteBatch(ctx context.Context, query string, cutoff int64) (int64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	res, err := c.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
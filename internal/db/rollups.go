@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// tableRollups are the rollup tables maintained by RefreshRollups, finest
+// first. Each one is built from the one before it, the first from raw prices.
+var tableRollups = []rollup{
+	{width: time.Minute, table: "price_rollups_1m"},
+	{width: 15 * time.Minute, table: "price_rollups_15m"},
+	{width: time.Hour, table: "price_rollups_1h"},
+	{width: 24 * time.Hour, table: "price_rollups_1d"},
+}
+
+// refreshRollupQuery aggregates a bucket source into a rollup table, $4 being
+// the rollup width in milliseconds.
+const refreshRollupQuery = `INSERT INTO %s (ticker, bucket, open, high, low, close, avg, samples)
+SELECT $1, ts - ts %% $4 AS b,
+	(array_agg(open ORDER BY ts ASC))[1],
+	MAX(high),
+	MIN(low),
+	(array_agg(close ORDER BY ts DESC))[1],
+	SUM(sum) / SUM(samples),
+	SUM(samples)
+FROM (%s) src
+GROUP BY b`
+
+// UseRollups switches price queries over to the rollup tables maintained by
+// RefreshRollups.
+func (c *Client) UseRollups() {
+	c.rollups = tableRollups
+}
+
+// MaintainRollups has AddPriceData refresh the rollups around the prices it
+// ingests in the same transaction, so rollups never miss committed prices.
+func (c *Client) MaintainRollups() {
+	c.maintainRollups = true
+}
+
+// RefreshRollups recomputes every rollup bucket of a ticker overlapping from
+// and to, in unix milliseconds, in a single transaction.
+func (c *Client) RefreshRollups(ctx context.Context, ticker string, from, to int64) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cutoff, err := getRawCutoff(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.refreshRollups(ctx, tx, cutoff, ticker, from, to); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// refreshNewRollups refreshes the rollups around newly ingested prices,
+// ordered by ticker and timestamp, one day of a ticker at a time so a payload
+// spanning months only touches the days it has prices on.
+func (c *Client) refreshNewRollups(ctx context.Context, tx *sql.Tx, prices []models.StockPrice) error {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	cutoff, err := getRawCutoff(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for len(prices) > 0 {
+		n := 1
+		for n < len(prices) && prices[n].Ticker == prices[0].Ticker && prices[n].Timestamp/day == prices[0].Timestamp/day {
+			n++
+		}
+
+		if err := c.refreshRollups(ctx, tx, cutoff, prices[0].Ticker, prices[0].Timestamp, prices[n-1].Timestamp); err != nil {
+			return err
+		}
+
+		prices = prices[n:]
+	}
+
+	return nil
+}
+
+// refreshRollups recomputes the rollup buckets of a ticker overlapping from
+// and to in tx. Only the finest rollup reads raw prices, so refreshing a whole
+// day costs a day of raw prices rather than a scan per rollup. Buckets before
+// the retention cutoff are left alone since their raw prices are gone.
+func (c *Client) refreshRollups(ctx context.Context, tx *sql.Tx, cutoff int64, ticker string, from, to int64) error {
+	if to < cutoff {
+		return nil
+	}
+	from = max(from, cutoff)
+
+	source := rawBucketSource
+	for _, r := range tableRollups {
+		width := r.width.Milliseconds()
+		start := from - from%width
+		end := to - to%width + width
+
+		if _, err := tx.ExecContext(ctx,
+			fmt.Sprintf("DELETE FROM %s WHERE ticker = $1 AND bucket >= $2 AND bucket < $3", r.table),
+			ticker, start, end,
+		); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", r.table, err)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			fmt.Sprintf(refreshRollupQuery, r.table, source),
+			ticker, start, end-1, width,
+		); err != nil {
+			c.log.Error("failed to execute SQL query", slog.String("ticker", ticker), slog.String("rollup", r.table), slog.String("error", err.Error()))
+			return fmt.Errorf("failed to refresh %s: %w", r.table, err)
+		}
+
+		source = fmt.Sprintf(rollupBucketSource, r.table)
+		from, to = start, end-1
+	}
+
+	return nil
+}
+
+// RebuildRollups recomputes the rollups of a ticker between from and to one
+// day at a time, so a long range does not hold a single huge transaction.
+// It returns the number of days rebuilt.
+func (c *Client) RebuildRollups(ctx context.Context, ticker string, from, to time.Time) (int, error) {
+	const day = 24 * time.Hour
+
+	days := 0
+	for start := from.Truncate(day); start.Before(to); start = start.Add(day) {
+		if err := c.RefreshRollups(ctx, ticker, start.UnixMilli(), start.Add(day).UnixMilli()-1); err != nil {
+			return days, fmt.Errorf("failed to rebuild %s: %w", start.Format(time.DateOnly), err)
+		}
+		days++
+	}
+
+	return days, nil
+}
+
+// GetPriceRange returns the timestamps of the first and last price of a
+// ticker, with ok false if it has none.
+func (c *Client) GetPriceRange(ctx context.Context, ticker string) (from, to time.Time, ok bool, err error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var first, last sql.NullInt64
+	err = c.db.QueryRowContext(ctx,
+		"SELECT MIN(timestamp), MAX(timestamp) FROM tickers WHERE ticker = $1",
+		ticker,
+	).Scan(&first, &last)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("failed to query price range: %w", err)
+	}
+
+	if !first.Valid {
+		return time.Time{}, time.Time{}, false, nil
+	}
+
+	return time.UnixMilli(first.Int64), time.UnixMilli(last.Int64), true, nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// AddScrapeRun records a finished scraper job run in the scrape_runs table.
+func (c *Client) AddScrapeRun(ctx context.Context, run models.ScrapeRun) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	query := c.sq.Insert("scrape_runs").
+		Columns("job", "started_at", "finished_at", "status", "error",
+			"rows_inserted", "rows_skipped", "upstream_latency_ms").
+		Values(run.Job, run.StartedAt.UnixMilli(), run.FinishedAt.UnixMilli(), string(run.Status), run.Error,
+			run.RowsInserted, run.RowsSkipped, run.UpstreamLatency.Milliseconds())
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	if _, err := c.db.ExecContext(ctx, sqlQuery, args...); err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("job", run.Job), slog.String("error", err.Error()))
+		return fmt.Errorf("failed to insert scrape run: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentScrapeRuns returns the latest scrape runs across all jobs, newest
+// first.
+func (c *Client) GetRecentScrapeRuns(ctx context.Context, limit int) ([]models.ScrapeRun, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select("id", "job", "started_at", "finished_at", "status", "error",
+		"rows_inserted", "rows_skipped", "upstream_latency_ms").
+		From("scrape_runs").
+		OrderBy("started_at DESC", "id DESC").
+		Limit(uint64(limit))
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query scrape runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.ScrapeRun
+	for rows.Next() {
+		var r models.ScrapeRun
+		var startedAt, finishedAt, latency int64
+		var status string
+		if err := rows.Scan(&r.ID, &r.Job, &startedAt, &finishedAt, &status, &r.Error,
+			&r.RowsInserted, &r.RowsSkipped, &latency); err != nil {
+			return nil, fmt.Errorf("failed to scan scrape run: %w", err)
+		}
+		r.StartedAt = time.UnixMilli(startedAt)
+		r.FinishedAt = time.UnixMilli(finishedAt)
+		r.Status = models.ScrapeRunStatus(status)
+		r.UpstreamLatency = time.Duration(latency) * time.Millisecond
+		runs = append(runs, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// GetScrapeFailureStreaks returns, for every job that is currently failing,
+// how many times it has failed since its last successful run.
+func (c *Client) GetScrapeFailureStreaks(ctx context.Context) ([]models.ScrapeFailureStreak, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		SELECT r.job, COUNT(*), MIN(r.started_at), (array_agg(r.error ORDER BY r.started_at DESC))[1]
+		FROM scrape_runs r
+		WHERE r.status = $1
+		AND r.started_at > COALESCE((
+			SELECT MAX(s.started_at) FROM scrape_runs s
+			WHERE s.job = r.job AND s.status = $2
+		), 0)
+		GROUP BY r.job
+		ORDER BY r.job`
+
+	rows, err := c.db.QueryContext(ctx, query, string(models.ScrapeRunFailed), string(models.ScrapeRunSuccess))
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query scrape failure streaks: %w", err)
+	}
+	defer rows.Close()
+
+	var streaks []models.ScrapeFailureStreak
+	for rows.Next() {
+		var s models.ScrapeFailureStreak
+		var since int64
+		if err := rows.Scan(&s.Job, &s.Failures, &since, &s.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan scrape failure streak: %w", err)
+		}
+		s.Since = time.UnixMilli(since)
+		streaks = append(streaks, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return streaks, nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+var snapshotCols = []string{
+	"ticker", "timestamp", "current_price", "average_price",
+	"highest_buy_order", "lowest_buy_order", "highest_sell_order", "lowest_sell_order",
+	"ipo_available", "ipo_price", "ipo_shares_left", "total_shares",
+	"today", "last_hour", "last_week",
+}
+
+// AddStockSnapshots stores the state of every stock at the given time in the
+// stock_snapshots table and returns the number of rows written.
+func (c *Client) AddStockSnapshots(ctx context.Context, at time.Time, stocks []stox.Stock) (int, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if len(stocks) == 0 {
+		return 0, nil
+	}
+
+	ts := at.UnixMilli()
+	query := c.sq.Insert("stock_snapshots").
+		Columns(snapshotCols...).
+		Suffix("ON CONFLICT (ticker, timestamp) DO NOTHING")
+	for _, s := range stocks {
+		query = query.Values(
+			s.TickerSymbol, ts, s.CurrentPrice, s.AveragePrice,
+			s.HighestBuyOrder, s.LowestBuyOrder, s.HighestSellOrder, s.LowestSellOrder,
+			s.IpoAvailable, s.IpoPrice, s.IpoSharesLeft, s.TotalShares,
+			s.Today, s.LastHour, s.LastWeek,
+		)
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	res, err := c.db.ExecContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("failed to insert stock snapshots: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// GetStockSnapshots returns the snapshots of a ticker between the given time
+// range, oldest first.
+func (c *Client) GetStockSnapshots(
+	ctx context.Context,
+	ticker string,
+	from, to time.Time,
+) ([]models.StockSnapshot, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	sb := c.sq.Select(snapshotCols...).From("stock_snapshots").
+		Where(squirrel.Eq{"ticker": ticker}).
+		Where("timestamp BETWEEN ? AND ?", from.UnixMilli(), to.UnixMilli()).
+		OrderBy("timestamp ASC")
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query stock snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []models.StockSnapshot
+	for rows.Next() {
+		var s models.StockSnapshot
+		if err := rows.Scan(
+			&s.Ticker, &s.Timestamp, &s.CurrentPrice, &s.AveragePrice,
+			&s.HighestBuyOrder, &s.LowestBuyOrder, &s.HighestSellOrder, &s.LowestSellOrder,
+			&s.IpoAvailable, &s.IpoPrice, &s.IpoSharesLeft, &s.TotalShares,
+			&s.Today, &s.LastHour, &s.LastWeek,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan stock snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
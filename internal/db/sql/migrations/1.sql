@@ -0,0 +1,25 @@
+-- name: up
+CREATE TABLE stock_snapshots (
+    ticker              VARCHAR(10)    NOT NULL,
+    timestamp           BIGINT         NOT NULL,
+    current_price       INTEGER        NOT NULL,
+    average_price       INTEGER        NOT NULL,
+    highest_buy_order   INTEGER        NOT NULL,
+    lowest_buy_order    INTEGER        NOT NULL,
+    highest_sell_order  INTEGER        NOT NULL,
+    lowest_sell_order   INTEGER        NOT NULL,
+    ipo_available       BOOLEAN        NOT NULL,
+    ipo_price           INTEGER        NOT NULL,
+    ipo_shares_left     INTEGER        NOT NULL,
+    total_shares        INTEGER        NOT NULL,
+    today               INTEGER        NOT NULL,
+    last_hour           INTEGER        NOT NULL,
+    last_week           INTEGER        NOT NULL,
+
+    PRIMARY KEY (ticker, timestamp)
+);
+
+CREATE INDEX idx_stock_snapshots_timestamp ON stock_snapshots(timestamp);
+
+-- name: down
+DROP TABLE IF EXISTS stock_snapshots;
@@ -0,0 +1,42 @@
+-- name: up
+CREATE TABLE clans (
+    tag         VARCHAR(32)    PRIMARY KEY,
+    rank        INTEGER        NOT NULL,
+    color       TEXT           NOT NULL,
+    emblem      TEXT           NOT NULL,
+    first_seen  BIGINT         NOT NULL,
+    last_seen   BIGINT         NOT NULL
+);
+
+CREATE TABLE users (
+    id            TEXT           PRIMARY KEY,
+    display_name  TEXT           NOT NULL,
+    color         TEXT           NOT NULL,
+    photo         TEXT           NOT NULL,
+    xp            INTEGER        NOT NULL,
+    clan_tag      VARCHAR(32)    REFERENCES clans(tag),
+    joined        BIGINT         NOT NULL,
+    medals        JSONB          NOT NULL DEFAULT '{}',
+    season_pass   BOOLEAN        NOT NULL,
+    bio           TEXT           NOT NULL,
+    first_seen    BIGINT         NOT NULL,
+    last_seen     BIGINT         NOT NULL
+);
+
+CREATE INDEX idx_users_clan_tag ON users(clan_tag);
+
+CREATE TABLE portfolio_values (
+    user_id    TEXT           NOT NULL REFERENCES users(id),
+    timestamp  BIGINT         NOT NULL,
+    value      BIGINT         NOT NULL,
+    rank       INTEGER        NOT NULL,
+
+    PRIMARY KEY (user_id, timestamp)
+);
+
+CREATE INDEX idx_portfolio_values_timestamp ON portfolio_values(timestamp);
+
+-- name: down
+DROP TABLE IF EXISTS portfolio_values;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS clans;
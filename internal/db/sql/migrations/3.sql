@@ -0,0 +1,18 @@
+-- name: up
+CREATE TABLE scrape_runs (
+    id                   BIGSERIAL      PRIMARY KEY,
+    job                  VARCHAR(64)    NOT NULL,
+    started_at           BIGINT         NOT NULL,
+    finished_at          BIGINT         NOT NULL,
+    status               VARCHAR(16)    NOT NULL,
+    error                TEXT           NOT NULL DEFAULT '',
+    rows_inserted        INTEGER        NOT NULL DEFAULT 0,
+    rows_skipped         INTEGER        NOT NULL DEFAULT 0,
+    upstream_latency_ms  INTEGER        NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_scrape_runs_job_started_at ON scrape_runs(job, started_at);
+CREATE INDEX idx_scrape_runs_started_at ON scrape_runs(started_at);
+
+-- name: down
+DROP TABLE IF EXISTS scrape_runs;
@@ -0,0 +1,14 @@
+-- name: up
+CREATE TABLE drift_events (
+    id           BIGSERIAL      PRIMARY KEY,
+    endpoint     TEXT           NOT NULL,
+    detected_at  BIGINT         NOT NULL,
+    signature    TEXT           NOT NULL,
+    issues       JSONB          NOT NULL,
+    payload      BYTEA          NOT NULL
+);
+
+CREATE INDEX idx_drift_events_detected_at ON drift_events(detected_at);
+
+-- name: down
+DROP TABLE IF EXISTS drift_events;
@@ -0,0 +1,12 @@
+-- name: up
+CREATE TABLE events (
+    seq         BIGSERIAL      PRIMARY KEY,
+    type        VARCHAR(64)    NOT NULL,
+    created_at  BIGINT         NOT NULL,
+    payload     JSONB          NOT NULL
+);
+
+CREATE INDEX idx_events_created_at ON events(created_at);
+
+-- name: down
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,104 @@
+-- name: up
+CREATE TABLE price_rollups_1m (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_15m (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_1h (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_1d (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+-- Roll up the prices already stored, the scraper only refreshes the days it
+-- ingests new prices on. Each rollup is built from the one before it.
+INSERT INTO price_rollups_1m (ticker, bucket, open, high, low, close, avg, samples)
+SELECT ticker, timestamp - timestamp % 60000 AS b,
+    (array_agg(value ORDER BY timestamp ASC))[1],
+    MAX(value),
+    MIN(value),
+    (array_agg(value ORDER BY timestamp DESC))[1],
+    AVG(value),
+    COUNT(*)
+FROM tickers
+GROUP BY ticker, b;
+
+INSERT INTO price_rollups_15m (ticker, bucket, open, high, low, close, avg, samples)
+SELECT ticker, bucket - bucket % 900000 AS b,
+    (array_agg(open ORDER BY bucket ASC))[1],
+    MAX(high),
+    MIN(low),
+    (array_agg(close ORDER BY bucket DESC))[1],
+    SUM(avg * samples) / SUM(samples),
+    SUM(samples)
+FROM price_rollups_1m
+GROUP BY ticker, b;
+
+INSERT INTO price_rollups_1h (ticker, bucket, open, high, low, close, avg, samples)
+SELECT ticker, bucket - bucket % 3600000 AS b,
+    (array_agg(open ORDER BY bucket ASC))[1],
+    MAX(high),
+    MIN(low),
+    (array_agg(close ORDER BY bucket DESC))[1],
+    SUM(avg * samples) / SUM(samples),
+    SUM(samples)
+FROM price_rollups_15m
+GROUP BY ticker, b;
+
+INSERT INTO price_rollups_1d (ticker, bucket, open, high, low, close, avg, samples)
+SELECT ticker, bucket - bucket % 86400000 AS b,
+    (array_agg(open ORDER BY bucket ASC))[1],
+    MAX(high),
+    MIN(low),
+    (array_agg(close ORDER BY bucket DESC))[1],
+    SUM(avg * samples) / SUM(samples),
+    SUM(samples)
+FROM price_rollups_1h
+GROUP BY ticker, b;
+
+-- name: down
+DROP TABLE IF EXISTS price_rollups_1d;
+DROP TABLE IF EXISTS price_rollups_1h;
+DROP TABLE IF EXISTS price_rollups_15m;
+DROP TABLE IF EXISTS price_rollups_1m;
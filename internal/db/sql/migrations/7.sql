@@ -0,0 +1,8 @@
+-- name: up
+CREATE TABLE retention_cutoffs (
+    table_name  VARCHAR(64)    PRIMARY KEY,
+    cutoff      BIGINT         NOT NULL
+);
+
+-- name: down
+DROP TABLE IF EXISTS retention_cutoffs;
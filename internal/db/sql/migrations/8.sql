@@ -0,0 +1,30 @@
+-- name: up
+CREATE TABLE tickers_catalog (
+    ticker        VARCHAR(10)    PRIMARY KEY,
+    first_seen    BIGINT         NOT NULL,
+    last_seen     BIGINT         NOT NULL,
+    ipo_price     INTEGER        NOT NULL,
+    total_shares  INTEGER        NOT NULL,
+    delisted      BOOLEAN        NOT NULL DEFAULT FALSE
+);
+
+-- Seed the catalog from stock snapshots, then from tickers that only have
+-- prices, so existing tickers are not treated as unknown.
+INSERT INTO tickers_catalog (ticker, first_seen, last_seen, ipo_price, total_shares)
+SELECT DISTINCT ON (ticker)
+    ticker,
+    MIN(timestamp) OVER (PARTITION BY ticker),
+    MAX(timestamp) OVER (PARTITION BY ticker),
+    ipo_price,
+    total_shares
+FROM stock_snapshots
+ORDER BY ticker, timestamp DESC;
+
+INSERT INTO tickers_catalog (ticker, first_seen, last_seen, ipo_price, total_shares)
+SELECT ticker, MIN(timestamp), MAX(timestamp), 0, 0
+FROM tickers
+GROUP BY ticker
+ON CONFLICT (ticker) DO NOTHING;
+
+-- name: down
+DROP TABLE IF EXISTS tickers_catalog;
@@ -0,0 +1,13 @@
+-- name: up
+CREATE TABLE unrecoverable_gaps (
+    ticker      VARCHAR(10)    NOT NULL,
+    gap_start   BIGINT         NOT NULL,
+    gap_end     BIGINT         NOT NULL,
+
+    PRIMARY KEY (ticker, gap_start, gap_end)
+);
+
+CREATE INDEX idx_unrecoverable_gaps_gap_end ON unrecoverable_gaps(gap_end);
+
+-- name: down
+DROP TABLE IF EXISTS unrecoverable_gaps;
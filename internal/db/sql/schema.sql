@@ -10,5 +10,189 @@ CREATE TABLE tickers (
 CREATE INDEX idx_ticker_only ON tickers(ticker);
 CREATE INDEX idx_timestamp_only ON tickers(timestamp);
 
+CREATE TABLE stock_snapshots (
+    ticker              VARCHAR(10)    NOT NULL,
+    timestamp           BIGINT         NOT NULL,
+    current_price       INTEGER        NOT NULL,
+    average_price       INTEGER        NOT NULL,
+    highest_buy_order   INTEGER        NOT NULL,
+    lowest_buy_order    INTEGER        NOT NULL,
+    highest_sell_order  INTEGER        NOT NULL,
+    lowest_sell_order   INTEGER        NOT NULL,
+    ipo_available       BOOLEAN        NOT NULL,
+    ipo_price           INTEGER        NOT NULL,
+    ipo_shares_left     INTEGER        NOT NULL,
+    total_shares        INTEGER        NOT NULL,
+    today               INTEGER        NOT NULL,
+    last_hour           INTEGER        NOT NULL,
+    last_week           INTEGER        NOT NULL,
+
+    PRIMARY KEY (ticker, timestamp)
+);
+
+CREATE INDEX idx_stock_snapshots_timestamp ON stock_snapshots(timestamp);
+
+CREATE TABLE clans (
+    tag         VARCHAR(32)    PRIMARY KEY,
+    rank        INTEGER        NOT NULL,
+    color       TEXT           NOT NULL,
+    emblem      TEXT           NOT NULL,
+    first_seen  BIGINT         NOT NULL,
+    last_seen   BIGINT         NOT NULL
+);
+
+CREATE TABLE users (
+    id            TEXT           PRIMARY KEY,
+    display_name  TEXT           NOT NULL,
+    color         TEXT           NOT NULL,
+    photo         TEXT           NOT NULL,
+    xp            INTEGER        NOT NULL,
+    clan_tag      VARCHAR(32)    REFERENCES clans(tag),
+    joined        BIGINT         NOT NULL,
+    medals        JSONB          NOT NULL DEFAULT '{}',
+    season_pass   BOOLEAN        NOT NULL,
+    bio           TEXT           NOT NULL,
+    first_seen    BIGINT         NOT NULL,
+    last_seen     BIGINT         NOT NULL
+);
+
+CREATE INDEX idx_users_clan_tag ON users(clan_tag);
+
+CREATE TABLE portfolio_values (
+    user_id    TEXT           NOT NULL REFERENCES users(id),
+    timestamp  BIGINT         NOT NULL,
+    value      BIGINT         NOT NULL,
+    rank       INTEGER        NOT NULL,
+
+    PRIMARY KEY (user_id, timestamp)
+);
+
+CREATE INDEX idx_portfolio_values_timestamp ON portfolio_values(timestamp);
+
+CREATE TABLE scrape_runs (
+    id                   BIGSERIAL      PRIMARY KEY,
+    job                  VARCHAR(64)    NOT NULL,
+    started_at           BIGINT         NOT NULL,
+    finished_at          BIGINT         NOT NULL,
+    status               VARCHAR(16)    NOT NULL,
+    error                TEXT           NOT NULL DEFAULT '',
+    rows_inserted        INTEGER        NOT NULL DEFAULT 0,
+    rows_skipped         INTEGER        NOT NULL DEFAULT 0,
+    upstream_latency_ms  INTEGER        NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_scrape_runs_job_started_at ON scrape_runs(job, started_at);
+CREATE INDEX idx_scrape_runs_started_at ON scrape_runs(started_at);
+
+CREATE TABLE drift_events (
+    id           BIGSERIAL      PRIMARY KEY,
+    endpoint     TEXT           NOT NULL,
+    detected_at  BIGINT         NOT NULL,
+    signature    TEXT           NOT NULL,
+    issues       JSONB          NOT NULL,
+    payload      BYTEA          NOT NULL
+);
+
+CREATE INDEX idx_drift_events_detected_at ON drift_events(detected_at);
+
+CREATE TABLE events (
+    seq         BIGSERIAL      PRIMARY KEY,
+    type        VARCHAR(64)    NOT NULL,
+    created_at  BIGINT         NOT NULL,
+    payload     JSONB          NOT NULL
+);
+
+CREATE INDEX idx_events_created_at ON events(created_at);
+
+CREATE TABLE price_rollups_1m (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_15m (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_1h (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE price_rollups_1d (
+    ticker   VARCHAR(10)         NOT NULL,
+    bucket   BIGINT              NOT NULL,
+    open     INTEGER             NOT NULL,
+    high     INTEGER             NOT NULL,
+    low      INTEGER             NOT NULL,
+    close    INTEGER             NOT NULL,
+    avg      DOUBLE PRECISION    NOT NULL,
+    samples  INTEGER             NOT NULL,
+
+    PRIMARY KEY (ticker, bucket)
+);
+
+CREATE TABLE retention_cutoffs (
+    table_name  VARCHAR(64)    PRIMARY KEY,
+    cutoff      BIGINT         NOT NULL
+);
+
+CREATE TABLE tickers_catalog (
+    ticker        VARCHAR(10)    PRIMARY KEY,
+    first_seen    BIGINT         NOT NULL,
+    last_seen     BIGINT         NOT NULL,
+    ipo_price     INTEGER        NOT NULL,
+    total_shares  INTEGER        NOT NULL,
+    delisted      BOOLEAN        NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE unrecoverable_gaps (
+    ticker      VARCHAR(10)    NOT NULL,
+    gap_start   BIGINT         NOT NULL,
+    gap_end     BIGINT         NOT NULL,
+
+    PRIMARY KEY (ticker, gap_start, gap_end)
+);
+
+CREATE INDEX idx_unrecoverable_gaps_gap_end ON unrecoverable_gaps(gap_end);
+
 -- name: schema_down
+DROP TABLE IF EXISTS unrecoverable_gaps;
+DROP TABLE IF EXISTS tickers_catalog;
+DROP TABLE IF EXISTS retention_cutoffs;
+DROP TABLE IF EXISTS price_rollups_1d;
+DROP TABLE IF EXISTS price_rollups_1h;
+DROP TABLE IF EXISTS price_rollups_15m;
+DROP TABLE IF EXISTS price_rollups_1m;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS drift_events;
+DROP TABLE IF EXISTS scrape_runs;
+DROP TABLE IF EXISTS portfolio_values;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS clans;
+DROP TABLE IF EXISTS stock_snapshots;
 DROP TABLE IF EXISTS tickers;
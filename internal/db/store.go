@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// PriceStore is the storage of prices and the tickers catalog that handlers
+// and the scraper read and write through. Client implements it on Postgres
+// and MemoryStore in memory, both following the semantics checked in
+// store_test.go.
+type PriceStore interface {
+	// AddPriceData stores every valid price of the payload, skipping ones
+	// that already exist, and returns the new rows in ticker and timestamp
+	// order.
+	AddPriceData(ctx context.Context, data stox.PriceData) (IngestResult, error)
+
+	// GetStockPricesByTimeFrame returns the prices of a ticker with a
+	// timestamp between from and to inclusive, oldest first.
+	GetStockPricesByTimeFrame(ctx context.Context, ticker string, from, to time.Time) ([]models.StockPrice, error)
+
+	// GetPriceBuckets aggregates the prices in the range of opts into its
+	// buckets, oldest first, like prices.Aggregate.
+	GetPriceBuckets(ctx context.Context, ticker string, opts prices.Options) ([]models.PriceBucket, error)
+
+	// GetTickers returns every ticker with prices in alphabetical order.
+	GetTickers(ctx context.Context) ([]string, error)
+
+	// GetPriceRange returns the first and last price timestamps of a ticker.
+	GetPriceRange(ctx context.Context, ticker string) (from, to time.Time, ok bool, err error)
+
+	// FindGaps returns the periods between from and to longer than maxGap
+	// without prices, oldest first, counting from and to as edges.
+	FindGaps(ctx context.Context, ticker string, from, to time.Time, maxGap time.Duration) ([]models.PriceGap, error)
+
+	// UpdateCatalog records the listed stocks and delists every ticker last
+	// seen before at. An empty listing is ignored.
+	UpdateCatalog(ctx context.Context, at time.Time, stocks []stox.Stock) error
+
+	// ListTickers returns the catalog in alphabetical order.
+	ListTickers(ctx context.Context, includeDelisted bool) ([]models.TickerInfo, error)
+
+	// SearchTickers returns up to limit tickers containing query case
+	// insensitively, prefix matches first, then listed before delisted, then
+	// alphabetically.
+	SearchTickers(ctx context.Context, query string, limit int) ([]models.TickerInfo, error)
+
+	// GetTicker returns the catalog entry of a ticker.
+	GetTicker(ctx context.Context, ticker string) (info models.TickerInfo, ok bool, err error)
+}
+
+// ScrapeStore is the audit log of scraper job runs and schema drift that the
+// scrapes page reads. Client implements it on Postgres and MemoryStore in
+// memory.
+type ScrapeStore interface {
+	// AddScrapeRun records a finished scraper job run.
+	AddScrapeRun(ctx context.Context, run models.ScrapeRun) error
+
+	// GetRecentScrapeRuns returns up to limit scrape runs across all jobs,
+	// newest first.
+	GetRecentScrapeRuns(ctx context.Context, limit int) ([]models.ScrapeRun, error)
+
+	// GetScrapeFailureStreaks returns, in job order, every job whose failed
+	// runs started after its last successful one.
+	GetScrapeFailureStreaks(ctx context.Context) ([]models.ScrapeFailureStreak, error)
+
+	// AddDriftEvent records a schema drift report.
+	AddDriftEvent(ctx context.Context, d stox.Drift) error
+
+	// GetRecentDriftEvents returns up to limit drift events, newest first.
+	GetRecentDriftEvents(ctx context.Context, limit int) ([]models.DriftEvent, error)
+}
+
+var (
+	_ PriceStore  = (*Client)(nil)
+	_ PriceStore  = (*MemoryStore)(nil)
+	_ ScrapeStore = (*Client)(nil)
+	_ ScrapeStore = (*MemoryStore)(nil)
+)
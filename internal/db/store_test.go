@@ -0,0 +1,562 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// base is the start of the prices written by the checks, aligned to a day so
+// bucket boundaries are easy to reason about.
+var base = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// store is implemented by both Client and MemoryStore.
+type store interface {
+	PriceStore
+	ScrapeStore
+}
+
+// storeChecks are the semantics every store implementation shares. Each one
+// is run against an empty store.
+var storeChecks = []struct {
+	name string
+	run  func(ctx context.Context, s store) error
+}{
+	{"ingest", checkIngest},
+	{"prices", checkPrices},
+	{"buckets", checkBuckets},
+	{"gaps", checkGaps},
+	{"catalog", checkCatalog},
+	{"scrapes", checkScrapes},
+}
+
+func runStoreChecks(t *testing.T, newStore func(t *testing.T) store) {
+	for _, c := range storeChecks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.run(context.Background(), newStore(t)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	runStoreChecks(t, func(t *testing.T) store {
+		return NewMemoryStore()
+	})
+}
+
+// TestPostgresStore runs the checks against the database in TEST_DB_HOST,
+// TEST_DB_USER, TEST_DB_PASS and TEST_DB_NAME. The database is migrated and
+// its price tables are truncated before every check, so it must be a scratch
+// database.
+func TestPostgresStore(t *testing.T) {
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		t.Skip("TEST_DB_HOST not set")
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	c, err := InitClient(log,
+		os.Getenv("TEST_DB_USER"), os.Getenv("TEST_DB_PASS"), host, os.Getenv("TEST_DB_NAME"),
+		true, time.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	if _, err := c.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	runStoreChecks(t, func(t *testing.T) store {
+		if err := truncate(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	})
+}
+
+// truncate removes every price, rollup, catalog entry, scrape run and drift
+// event of c.
+func truncate(ctx context.Context, c *Client) error {
+	tables := []string{"tickers", "tickers_catalog", "retention_cutoffs", "scrape_runs", "drift_events"}
+	for _, r := range tableRollups {
+		tables = append(tables, r.table)
+	}
+
+	_, err := c.db.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", "))
+	return err
+}
+
+// at returns the millisecond timestamp d after base.
+func at(d time.Duration) int64 {
+	return base.Add(d).UnixMilli()
+}
+
+// pricesOf builds a single ticker payload from timestamp and price pairs.
+func pricesOf(ticker string, points map[int64]int) stox.PriceData {
+	series := make(map[string]int, len(points))
+	for ts, price := range points {
+		series[strconv.FormatInt(ts, 10)] = price
+	}
+	return stox.PriceData{Prices: map[string]map[string]int{ticker: series}}
+}
+
+// whole returns a price of n ₣.
+func whole(n int) models.Price {
+	return models.PriceFromInt(n)
+}
+
+func expect(what string, got, want any) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s: got %+v, want %+v", what, got, want)
+	}
+	return nil
+}
+
+func checkIngest(ctx context.Context, s store) error {
+	data := pricesOf("BBB", map[int64]int{at(2 * time.Minute): 12, at(time.Minute): 11})
+	data.Prices["AAA"] = map[string]int{
+		strconv.FormatInt(at(0), 10): 10,
+		"not-a-timestamp":            99,
+	}
+
+	res, err := s.AddPriceData(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	want := []models.StockPrice{
+		{Ticker: "AAA", Timestamp: at(0), Value: whole(10)},
+		{Ticker: "BBB", Timestamp: at(time.Minute), Value: whole(11)},
+		{Ticker: "BBB", Timestamp: at(2 * time.Minute), Value: whole(12)},
+	}
+	if err := errors.Join(
+		expect("inserted", res.Inserted, 3),
+		expect("skipped", res.Skipped, 1),
+		expect("prices", res.Prices, want),
+	); err != nil {
+		return err
+	}
+
+	// Existing prices are skipped, even with a different value.
+	res, err = s.AddPriceData(ctx, pricesOf("BBB", map[int64]int{at(time.Minute): 50, at(3 * time.Minute): 13}))
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(
+		expect("reingest inserted", res.Inserted, 1),
+		expect("reingest skipped", res.Skipped, 1),
+		expect("reingest prices", res.Prices, []models.StockPrice{{Ticker: "BBB", Timestamp: at(3 * time.Minute), Value: whole(13)}}),
+	); err != nil {
+		return err
+	}
+
+	tickers, err := s.GetTickers(ctx)
+	if err != nil {
+		return err
+	}
+	if err := expect("tickers", tickers, []string{"AAA", "BBB"}); err != nil {
+		return err
+	}
+
+	from, to, ok, err := s.GetPriceRange(ctx, "BBB")
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(
+		expect("range ok", ok, true),
+		expect("range from", from.UnixMilli(), at(time.Minute)),
+		expect("range to", to.UnixMilli(), at(3*time.Minute)),
+	); err != nil {
+		return err
+	}
+
+	_, _, ok, err = s.GetPriceRange(ctx, "NONE")
+	if err != nil {
+		return err
+	}
+	return expect("unknown range ok", ok, false)
+}
+
+func checkPrices(ctx context.Context, s store) error {
+	_, err := s.AddPriceData(ctx, pricesOf("AAA", map[int64]int{
+		at(0): 1, at(time.Minute): 2, at(2 * time.Minute): 3, at(3 * time.Minute): 4,
+	}))
+	if err != nil {
+		return err
+	}
+
+	// Both ends of the range are inclusive.
+	got, err := s.GetStockPricesByTimeFrame(ctx, "AAA", base.Add(time.Minute), base.Add(2*time.Minute))
+	if err != nil {
+		return err
+	}
+	want := []models.StockPrice{
+		{Ticker: "AAA", Timestamp: at(time.Minute), Value: whole(2)},
+		{Ticker: "AAA", Timestamp: at(2 * time.Minute), Value: whole(3)},
+	}
+	if err := expect("prices", got, want); err != nil {
+		return err
+	}
+
+	got, err = s.GetStockPricesByTimeFrame(ctx, "NONE", base, base.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	return expect("unknown ticker prices", len(got), 0)
+}
+
+func checkBuckets(ctx context.Context, s store) error {
+	_, err := s.AddPriceData(ctx, pricesOf("AAA", map[int64]int{
+		at(-time.Minute):     100,
+		at(0):                10,
+		at(20 * time.Minute): 30,
+		at(40 * time.Minute): 6,
+		at(2 * time.Hour):    7,
+	}))
+	if err != nil {
+		return err
+	}
+
+	hour := prices.Options{Range: models.TimeRange{From: base, To: base.Add(2 * time.Hour)}, Width: time.Hour}
+
+	zero := hour
+	zero.Width = 0
+	if _, err := s.GetPriceBuckets(ctx, "AAA", zero); err == nil {
+		return fmt.Errorf("expected an error for a zero width")
+	}
+
+	// Buckets are aligned to the epoch, not to from, and empty ones are left
+	// out. Averages are rounded to the price precision.
+	epoch := hour
+	epoch.Range.From = base.Add(-time.Minute)
+	got, err := s.GetPriceBuckets(ctx, "AAA", epoch)
+	if err != nil {
+		return err
+	}
+	want := []models.PriceBucket{
+		{Ticker: "AAA", Start: at(-time.Hour), End: at(0), Open: whole(100), High: whole(100), Low: whole(100), Close: whole(100), Avg: whole(100), Samples: 1},
+		{Ticker: "AAA", Start: at(0), End: at(time.Hour), Open: whole(10), High: whole(30), Low: whole(6), Close: whole(6), Avg: models.Price(1533), Samples: 3},
+		{Ticker: "AAA", Start: at(2 * time.Hour), End: at(3 * time.Hour), Open: whole(7), High: whole(7), Low: whole(7), Close: whole(7), Avg: whole(7), Samples: 1},
+	}
+	if err := expect("buckets", got, want); err != nil {
+		return err
+	}
+
+	start := epoch
+	start.Align = prices.AlignRangeStart
+	got, err = s.GetPriceBuckets(ctx, "AAA", start)
+	if err != nil {
+		return err
+	}
+	want = []models.PriceBucket{
+		{Ticker: "AAA", Start: at(-time.Minute), End: at(59 * time.Minute), Open: whole(100), High: whole(100), Low: whole(6), Close: whole(6), Avg: models.Price(3650), Samples: 4},
+		{Ticker: "AAA", Start: at(119 * time.Minute), End: at(179 * time.Minute), Open: whole(7), High: whole(7), Low: whole(7), Close: whole(7), Avg: whole(7), Samples: 1},
+	}
+	if err := expect("range start buckets", got, want); err != nil {
+		return err
+	}
+
+	// Midnight in New York is 05:00 UTC in January.
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return err
+	}
+	calendar := prices.Options{
+		Range: models.TimeRange{From: base.Add(-24 * time.Hour).In(ny), To: base.Add(2 * time.Hour).In(ny)},
+		Width: 24 * time.Hour,
+		Align: prices.AlignCalendar,
+	}
+	got, err = s.GetPriceBuckets(ctx, "AAA", calendar)
+	if err != nil {
+		return err
+	}
+	want = []models.PriceBucket{
+		{Ticker: "AAA", Start: at(-19 * time.Hour), End: at(5 * time.Hour), Open: whole(100), High: whole(100), Low: whole(6), Close: whole(7), Avg: models.Price(3060), Samples: 5},
+	}
+	if err := expect("calendar buckets", got, want); err != nil {
+		return err
+	}
+
+	carry := hour
+	carry.Empty = prices.EmptyCarryForward
+	got, err = s.GetPriceBuckets(ctx, "AAA", carry)
+	if err != nil {
+		return err
+	}
+	want = []models.PriceBucket{
+		{Ticker: "AAA", Start: at(0), End: at(time.Hour), Open: whole(10), High: whole(30), Low: whole(6), Close: whole(6), Avg: models.Price(1533), Samples: 3},
+		{Ticker: "AAA", Start: at(time.Hour), End: at(2 * time.Hour), Open: whole(6), High: whole(6), Low: whole(6), Close: whole(6), Avg: whole(6)},
+		{Ticker: "AAA", Start: at(2 * time.Hour), End: at(3 * time.Hour), Open: whole(7), High: whole(7), Low: whole(7), Close: whole(7), Avg: whole(7), Samples: 1},
+	}
+	if err := expect("carried forward buckets", got, want); err != nil {
+		return err
+	}
+
+	null := hour
+	null.Empty = prices.EmptyNull
+	got, err = s.GetPriceBuckets(ctx, "AAA", null)
+	if err != nil {
+		return err
+	}
+	want[1] = models.PriceBucket{Ticker: "AAA", Start: at(time.Hour), End: at(2 * time.Hour)}
+	return expect("null buckets", got, want)
+}
+
+func checkGaps(ctx context.Context, s store) error {
+	_, err := s.AddPriceData(ctx, pricesOf("AAA", map[int64]int{
+		at(0): 1, at(time.Minute): 2, at(10 * time.Minute): 3, at(11 * time.Minute): 4, at(30 * time.Minute): 5,
+	}))
+	if err != nil {
+		return err
+	}
+
+	got, err := s.FindGaps(ctx, "AAA", base, base.Add(11*time.Minute), 8*time.Minute)
+	if err != nil {
+		return err
+	}
+	want := []models.PriceGap{{Ticker: "AAA", From: at(time.Minute), To: at(10 * time.Minute)}}
+	if err := expect("gaps", got, want); err != nil {
+		return err
+	}
+
+	// A gap of exactly maxGap is not reported.
+	got, err = s.FindGaps(ctx, "AAA", base, base.Add(11*time.Minute), 9*time.Minute)
+	if err != nil {
+		return err
+	}
+	if err := expect("max gap", len(got), 0); err != nil {
+		return err
+	}
+
+	// The window bounds are edges and only prices in it count, the last one
+	// would end the trailing gap.
+	got, err = s.FindGaps(ctx, "AAA", base.Add(-10*time.Minute), base.Add(29*time.Minute), 8*time.Minute)
+	if err != nil {
+		return err
+	}
+	want = []models.PriceGap{
+		{Ticker: "AAA", From: at(-10 * time.Minute), To: at(0)},
+		{Ticker: "AAA", From: at(time.Minute), To: at(10 * time.Minute)},
+		{Ticker: "AAA", From: at(11 * time.Minute), To: at(29 * time.Minute)},
+	}
+	if err := expect("edge gaps", got, want); err != nil {
+		return err
+	}
+
+	got, err = s.FindGaps(ctx, "NONE", base, base.Add(time.Hour), 8*time.Minute)
+	if err != nil {
+		return err
+	}
+	return expect("empty window gaps", got, []models.PriceGap{{Ticker: "NONE", From: at(0), To: at(time.Hour)}})
+}
+
+func checkCatalog(ctx context.Context, s store) error {
+	first := base
+	second := base.Add(time.Minute)
+
+	err := s.UpdateCatalog(ctx, first, []stox.Stock{
+		{TickerSymbol: "FISH", IpoPrice: 100, TotalShares: 1000},
+		{TickerSymbol: "TANK", IpoPrice: 200, TotalShares: 2000},
+		{TickerSymbol: "SHARK", IpoPrice: 300, TotalShares: 3000},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.UpdateCatalog(ctx, second, []stox.Stock{
+		{TickerSymbol: "FISH", IpoPrice: 100, TotalShares: 1500},
+		{TickerSymbol: "SHARK", IpoPrice: 300, TotalShares: 3000},
+	})
+	if err != nil {
+		return err
+	}
+
+	// An empty listing does not delist everything.
+	if err := s.UpdateCatalog(ctx, second.Add(time.Minute), nil); err != nil {
+		return err
+	}
+
+	fish, ok, err := s.GetTicker(ctx, "FISH")
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(
+		expect("FISH ok", ok, true),
+		expect("FISH first seen", fish.FirstSeen.UnixMilli(), first.UnixMilli()),
+		expect("FISH last seen", fish.LastSeen.UnixMilli(), second.UnixMilli()),
+		expect("FISH total shares", fish.TotalShares, 1500),
+		expect("FISH delisted", fish.Delisted, false),
+	); err != nil {
+		return err
+	}
+
+	if _, ok, err := s.GetTicker(ctx, "NONE"); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("unknown ticker found")
+	}
+
+	listed, err := s.ListTickers(ctx, false)
+	if err != nil {
+		return err
+	}
+	all, err := s.ListTickers(ctx, true)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(
+		expect("listed", tickerNames(listed), []string{"FISH", "SHARK"}),
+		expect("all", tickerNames(all), []string{"FISH", "SHARK", "TANK"}),
+	); err != nil {
+		return err
+	}
+
+	// Prefix matches come first, then listed before delisted tickers.
+	found, err := s.SearchTickers(ctx, "a", 10)
+	if err != nil {
+		return err
+	}
+	if err := expect("search", tickerNames(found), []string{"SHARK", "TANK"}); err != nil {
+		return err
+	}
+
+	found, err = s.SearchTickers(ctx, "s", 10)
+	if err != nil {
+		return err
+	}
+	if err := expect("prefix search", tickerNames(found), []string{"SHARK", "FISH"}); err != nil {
+		return err
+	}
+
+	found, err = s.SearchTickers(ctx, "", 2)
+	if err != nil {
+		return err
+	}
+	if err := expect("limited search", tickerNames(found), []string{"FISH", "SHARK"}); err != nil {
+		return err
+	}
+
+	// Search patterns are matched literally.
+	found, err = s.SearchTickers(ctx, "%", 10)
+	if err != nil {
+		return err
+	}
+	if err := expect("literal search", len(found), 0); err != nil {
+		return err
+	}
+
+	// Tickers with prices are catalogued before the next snapshot lists them,
+	// without touching the ones already there.
+	if _, err := s.AddPriceData(ctx, pricesOf("EEL", map[int64]int{at(0): 5})); err != nil {
+		return err
+	}
+	if _, err := s.AddPriceData(ctx, pricesOf("FISH", map[int64]int{at(0): 5})); err != nil {
+		return err
+	}
+
+	eel, ok, err := s.GetTicker(ctx, "EEL")
+	if err != nil {
+		return err
+	}
+	fish, _, err = s.GetTicker(ctx, "FISH")
+	if err != nil {
+		return err
+	}
+	return errors.Join(
+		expect("EEL ok", ok, true),
+		expect("EEL delisted", eel.Delisted, false),
+		expect("FISH total shares after prices", fish.TotalShares, 1500),
+	)
+}
+
+func checkScrapes(ctx context.Context, s store) error {
+	runs := []models.ScrapeRun{
+		{Job: "prices", StartedAt: base, Status: models.ScrapeRunFailed, Error: "old"},
+		{Job: "prices", StartedAt: base.Add(time.Minute), Status: models.ScrapeRunSuccess},
+		{Job: "prices", StartedAt: base.Add(2 * time.Minute), Status: models.ScrapeRunFailed, Error: "first"},
+		{Job: "prices", StartedAt: base.Add(3 * time.Minute), Status: models.ScrapeRunCancelled},
+		{Job: "prices", StartedAt: base.Add(4 * time.Minute), Status: models.ScrapeRunFailed, Error: "second"},
+		{Job: "backfill", StartedAt: base.Add(time.Minute), Status: models.ScrapeRunFailed, Error: "never worked"},
+		{Job: "stocks", StartedAt: base.Add(2 * time.Minute), Status: models.ScrapeRunSuccess},
+	}
+	for _, r := range runs {
+		r.FinishedAt = r.StartedAt.Add(time.Second)
+		if err := s.AddScrapeRun(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	recent, err := s.GetRecentScrapeRuns(ctx, 3)
+	if err != nil {
+		return err
+	}
+	var jobs []string
+	for _, r := range recent {
+		jobs = append(jobs, r.Job+" "+string(r.Status))
+	}
+	// Runs starting together are newest recorded first.
+	if err := expect("recent runs", jobs, []string{"prices failed", "prices cancelled", "stocks success"}); err != nil {
+		return err
+	}
+
+	// Cancelled runs neither count as failures nor end a streak.
+	streaks, err := s.GetScrapeFailureStreaks(ctx)
+	if err != nil {
+		return err
+	}
+	if err := expect("streaks", streaks, []models.ScrapeFailureStreak{
+		{Job: "backfill", Failures: 1, Since: time.UnixMilli(at(time.Minute)), LastError: "never worked"},
+		{Job: "prices", Failures: 2, Since: time.UnixMilli(at(2 * time.Minute)), LastError: "second"},
+	}); err != nil {
+		return err
+	}
+
+	issues := []stox.SchemaIssue{{Kind: stox.IssueMissingField, Path: "prices", Count: 1}}
+	drift := []stox.Drift{
+		{Endpoint: "/stocks", FetchedAt: base, Issues: issues, Payload: []byte("{}")},
+		{Endpoint: "/prices", FetchedAt: base.Add(time.Minute), Payload: []byte("{}")},
+	}
+	for _, d := range drift {
+		if err := s.AddDriftEvent(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	events, err := s.GetRecentDriftEvents(ctx, 10)
+	if err != nil {
+		return err
+	}
+	if len(events) != 2 {
+		return fmt.Errorf("drift events: got %d, want 2", len(events))
+	}
+	for i, e := range events {
+		d := drift[len(drift)-1-i]
+		if e.Endpoint != d.Endpoint || !e.DetectedAt.Equal(d.FetchedAt) || !reflect.DeepEqual(e.Issues, d.Issues) {
+			return fmt.Errorf("drift event %d: got %+v, want %+v", i, e, d)
+		}
+	}
+
+	return nil
+}
+
+func tickerNames(tickers []models.TickerInfo) []string {
+	names := make([]string, 0, len(tickers))
+	for _, t := range tickers {
+		names = append(names, t.Ticker)
+	}
+	return names
+}
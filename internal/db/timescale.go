@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// maxChartPoints is the number of rows a price query aims to stay under when
+// picking between raw prices and a rollup.
+const maxChartPoints = 2000
+
+// rawResolution is roughly how far apart raw prices are.
+const rawResolution = time.Minute
+
+// rollup is a table or view of OHLC buckets of a fixed width, with columns
+// ticker, bucket, open, high, low, close, avg and samples.
+type rollup struct {
+	width time.Duration
+	table string
+}
+
+// timescaleRollups are the continuous aggregates created by SetupTimescale,
+// finest first.
+var timescaleRollups = []rollup{
+	{width: time.Minute, table: "tickers_ohlc_1m"},
+	{width: 5 * time.Minute, table: "tickers_ohlc_5m"},
+	{width: time.Hour, table: "tickers_ohlc_1h"},
+	{width: 24 * time.Hour, table: "tickers_ohlc_1d"},
+}
+
+// SetupTimescale converts the tickers table into a TimescaleDB hypertable,
+// creates the continuous aggregates with their refresh policies, and switches
+// price queries over to the aggregates. Chunks are compressed once older than
+// compressAfter and dropped once older than dropAfter, a zero duration
+// disables either policy. Every step is idempotent so it is safe to run on
+// every start.
+func (c *Client) SetupTimescale(ctx context.Context, compressAfter, dropAfter time.Duration) error {
+	// Dropping raw chunks inside the refresh window of an aggregate would wipe
+	// the buckets built from them on the next refresh.
+	if coarsest := timescaleRollups[len(timescaleRollups)-1]; dropAfter > 0 && dropAfter <= 4*coarsest.width {
+		return fmt.Errorf("drop after must be longer than %s", 4*coarsest.width)
+	}
+
+	stmts := []string{
+		"CREATE EXTENSION IF NOT EXISTS timescaledb",
+		`SELECT create_hypertable('tickers', 'timestamp',
+			chunk_time_interval => 86400000::BIGINT,
+			migrate_data => true,
+			if_not_exists => true)`,
+		// Integer time columns need a now function for the aggregate and
+		// retention policies to know what "recent" means.
+		`CREATE OR REPLACE FUNCTION tickers_now_ms() RETURNS BIGINT
+			LANGUAGE SQL STABLE AS $$ SELECT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT $$`,
+		"SELECT set_integer_now_func('tickers', 'tickers_now_ms', replace_if_exists => true)",
+	}
+
+	for _, r := range timescaleRollups {
+		width := r.width.Milliseconds()
+		stmts = append(stmts,
+			fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s
+				WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+				SELECT ticker,
+					time_bucket(%d::BIGINT, timestamp) AS bucket,
+					first(value, timestamp) AS open,
+					max(value) AS high,
+					min(value) AS low,
+					last(value, timestamp) AS close,
+					avg(value) AS avg,
+					count(*) AS samples
+				FROM tickers
+				GROUP BY ticker, bucket
+				WITH NO DATA`, r.table, width),
+			// Refresh a window of four buckets behind now, anything newer
+			// is served from raw rows by real time aggregation.
+			fmt.Sprintf(`SELECT add_continuous_aggregate_policy('%s',
+				start_offset => %d::BIGINT,
+				end_offset => %d::BIGINT,
+				schedule_interval => INTERVAL '%d milliseconds',
+				if_not_exists => true)`, r.table, 4*width, width, width),
+		)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set up timescale: %w", err)
+		}
+	}
+
+	if compressAfter > 0 {
+		if err := c.enableCompression(ctx, compressAfter); err != nil {
+			return err
+		}
+	}
+
+	if dropAfter > 0 {
+		if _, err := c.db.ExecContext(ctx,
+			fmt.Sprintf("SELECT add_retention_policy('tickers', drop_after => %d::BIGINT, if_not_exists => true)",
+				dropAfter.Milliseconds()),
+		); err != nil {
+			return fmt.Errorf("failed to set up timescale retention: %w", err)
+		}
+	}
+
+	c.log.Info("timescale storage enabled",
+		slog.Duration("compressAfter", compressAfter),
+		slog.Duration("dropAfter", dropAfter))
+
+	c.UseTimescale()
+	return nil
+}
+
+// enableCompression turns on compression of the tickers table and adds its
+// policy. Compression settings cannot be changed once compressed chunks exist,
+// so they are only set when compression is not enabled yet.
+func (c *Client) enableCompression(ctx context.Context, compressAfter time.Duration) error {
+	var enabled bool
+	err := c.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM timescaledb_information.compression_settings
+			WHERE hypertable_name = 'tickers'
+		)`).Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("failed to check timescale compression: %w", err)
+	}
+
+	if !enabled {
+		if _, err := c.db.ExecContext(ctx, `
+			ALTER TABLE tickers SET (
+				timescaledb.compress,
+				timescaledb.compress_segmentby = 'ticker',
+				timescaledb.compress_orderby = 'timestamp')`); err != nil {
+			return fmt.Errorf("failed to enable timescale compression: %w", err)
+		}
+	}
+
+	if _, err := c.db.ExecContext(ctx,
+		fmt.Sprintf("SELECT add_compression_policy('tickers', compress_after => %d::BIGINT, if_not_exists => true)",
+			compressAfter.Milliseconds()),
+	); err != nil {
+		return fmt.Errorf("failed to add timescale compression policy: %w", err)
+	}
+
+	return nil
+}
+
+// UseTimescale switches price queries over to the continuous aggregates
+// created by SetupTimescale, without touching the schema.
+func (c *Client) UseTimescale() {
+	c.rollups = timescaleRollups
+}
+
+// pickRollup returns the finest rollup that keeps a time range under
+// maxChartPoints rows, or false when raw prices are fine.
+func (c *Client) pickRollup(from, to time.Time) (rollup, bool) {
+	span := to.Sub(from)
+	if len(c.rollups) == 0 || span/rawResolution <= maxChartPoints {
+		return rollup{}, false
+	}
+
+	for _, r := range c.rollups {
+		if span/r.width <= maxChartPoints {
+			return r, true
+		}
+	}
+
+	return c.rollups[len(c.rollups)-1], true
+}
+
+// getRollupPrices returns the close of every bucket of a rollup in a time
+// range as prices stamped at the start of the bucket.
+func (c *Client) getRollupPrices(ctx context.Context, r rollup, ticker string, from, to time.Time) ([]models.StockPrice, error) {
+	sb := c.sq.Select("ticker", "bucket", "close").From(r.table).
+		Where(squirrel.Eq{"ticker": ticker}).
+		Where("bucket BETWEEN ? AND ?", from.UnixMilli(), to.UnixMilli()).
+		OrderBy("bucket ASC")
+
+	sqlQuery, args, err := sb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SQL query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		c.log.Error("failed to execute SQL query", slog.Any("ticker", ticker), slog.String("rollup", r.table), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to query %s: %w", r.table, err)
+	}
+	defer rows.Close()
+
+	var prices []models.StockPrice
+	for rows.Next() {
+		var sp models.StockPrice
+		if err := rows.Scan(&sp.Ticker, &sp.Timestamp, &sp.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", r.table, err)
+		}
+		prices = append(prices, sp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
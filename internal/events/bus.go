@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+)
+
+// Latest subscribes to events published from the moment of subscribing,
+// skipping everything already stored.
+const Latest int64 = -1
+
+const (
+	// catchUpBatch is the number of events read per query while catching up.
+	catchUpBatch = 500
+
+	// pollInterval is how often subscribers check for events regardless of
+	// notifications, covering any notification lost in transit.
+	pollInterval = 30 * time.Second
+
+	// listenMinBackoff and listenMaxBackoff bound the wait before trying to
+	// listen for notifications again after failing to.
+	listenMinBackoff = time.Second
+	listenMaxBackoff = time.Minute
+)
+
+// Bus publishes events into the events table and delivers them to
+// subscribers in other processes. Postgres NOTIFY only wakes subscribers up,
+// the events themselves are always read back by sequence number, so a
+// subscriber that disconnects catches up on everything it missed.
+type Bus struct {
+	log *slog.Logger
+	db  *db.Client
+}
+
+func New(log *slog.Logger, db *db.Client) *Bus {
+	return &Bus{
+		log: log,
+		db:  db,
+	}
+}
+
+// Publish stores an event and wakes up every subscriber, returning the
+// sequence number of the event.
+func (b *Bus) Publish(ctx context.Context, p Payload) (int64, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal %s event: %w", p.EventType(), err)
+	}
+
+	seq, err := b.db.AddEvent(ctx, string(p.EventType()), time.Now(), payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish %s event: %w", p.EventType(), err)
+	}
+
+	return seq, nil
+}
+
+// Subscribe calls fn with every event with a sequence number greater than
+// after, in order, until ctx is cancelled. Pass Latest to only receive events
+// published from now on. fn is called from a single goroutine and should not
+// block for long, as it holds up delivery of later events.
+func (b *Bus) Subscribe(ctx context.Context, after int64, fn func(Event)) error {
+	if after == Latest {
+		seq, err := b.db.GetLatestEventSeq(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get latest event: %w", err)
+		}
+		after = seq
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The payload of a notification is the new sequence number, but it is
+	// only used as a wake up call since reading by sequence number also picks
+	// up anything missed while disconnected.
+	wake := make(chan struct{}, 1)
+	go b.listen(ctx, func(string) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		after = b.catchUp(ctx, after, fn)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// listen listens for event notifications until ctx is cancelled, trying again
+// with backoff whenever listening fails. Events keep being polled for in the
+// meantime.
+func (b *Bus) listen(ctx context.Context, fn func(string)) {
+	backoff := listenMinBackoff
+	for {
+		err := b.db.Listen(ctx, db.EventsChannel, fn)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		b.log.Error("failed to listen for events", slog.Duration("retry_in", backoff), slog.Any("error", err))
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(backoff*2, listenMaxBackoff)
+	}
+}
+
+// catchUp delivers every stored event after seq and returns the sequence
+// number of the last one delivered. Errors are logged and retried on the
+// next wake up.
+func (b *Bus) catchUp(ctx context.Context, seq int64, fn func(Event)) int64 {
+	for {
+		stored, err := b.db.GetEventsAfter(ctx, seq, catchUpBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				b.log.Error("failed to read events", slog.Int64("after", seq), slog.Any("error", err))
+			}
+			return seq
+		}
+
+		for _, e := range stored {
+			fn(Event{
+				Seq:       e.Seq,
+				Type:      Type(e.Type),
+				CreatedAt: e.CreatedAt,
+				Payload:   e.Payload,
+			})
+			seq = e.Seq
+		}
+
+		if len(stored) < catchUpBatch {
+			return seq
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+type Type string
+
+const (
+	TypeNewPrices    Type = db.EventNewPrices
+	TypeNewSnapshot  Type = "snapshot.new"
+	TypeScrapeFailed Type = "scrape.failed"
+	TypeAlertFired   Type = "alert.fired"
+)
+
+// Payload is implemented by every event payload.
+type Payload interface {
+	EventType() Type
+}
+
+// NewPrices is stored along with newly ingested prices of a ticker, in the
+// same transaction, and holds exactly the prices inserted.
+type NewPrices models.PriceSpan
+
+func (NewPrices) EventType() Type { return TypeNewPrices }
+
+// NewSnapshot is published after a stocks snapshot is stored.
+type NewSnapshot struct {
+	At     time.Time `json:"at"`
+	Stocks int       `json:"stocks"`
+}
+
+func (NewSnapshot) EventType() Type { return TypeNewSnapshot }
+
+// ScrapeFailed is published when a scrape job run fails.
+type ScrapeFailed struct {
+	Job       string    `json:"job"`
+	StartedAt time.Time `json:"startedAt"`
+	Error     string    `json:"error"`
+}
+
+func (ScrapeFailed) EventType() Type { return TypeScrapeFailed }
+
+// AlertFired is published when an alert rule triggers.
+type AlertFired struct {
+	Name    string       `json:"name"`
+	Ticker  string       `json:"ticker"`
+	Value   models.Price `json:"value"`
+	Message string       `json:"message"`
+}
+
+func (AlertFired) EventType() Type { return TypeAlertFired }
+
+// Event is a published event as received by subscribers.
+type Event struct {
+	Seq       int64
+	Type      Type
+	CreatedAt time.Time
+	Payload   json.RawMessage
+}
+
+// Decode unmarshals the event payload into p, which must be the payload type
+// matching the event type.
+func (e Event) Decode(p Payload) error {
+	if p.EventType() != e.Type {
+		return fmt.Errorf("cannot decode %s event into %T", e.Type, p)
+	}
+
+	if err := json.Unmarshal(e.Payload, p); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+
+	return nil
+}
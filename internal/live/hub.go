@@ -0,0 +1,87 @@
+package live
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// subscriberBuffer is how many updates a subscriber can fall behind before
+// further updates to it are dropped.
+const subscriberBuffer = 16
+
+// Hub listens for newly ingested prices on the event bus and fans them out to
+// the subscribers of each ticker.
+type Hub struct {
+	log *slog.Logger
+	bus *events.Bus
+
+	mu   sync.Mutex
+	subs map[string]map[chan []models.StockPrice]struct{}
+}
+
+func NewHub(log *slog.Logger, bus *events.Bus) *Hub {
+	return &Hub{
+		log:  log,
+		bus:  bus,
+		subs: map[string]map[chan []models.StockPrice]struct{}{},
+	}
+}
+
+// Run listens for new prices until ctx is cancelled.
+func (h *Hub) Run(ctx context.Context) error {
+	return h.bus.Subscribe(ctx, events.Latest, func(e events.Event) {
+		h.dispatch(e)
+	})
+}
+
+// Subscribe returns a channel receiving new prices of a ticker and a function
+// to unsubscribe, which must be called once the caller is done.
+func (h *Hub) Subscribe(ticker string) (<-chan []models.StockPrice, func()) {
+	ch := make(chan []models.StockPrice, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[ticker] == nil {
+		h.subs[ticker] = map[chan []models.StockPrice]struct{}{}
+	}
+	h.subs[ticker][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subs[ticker], ch)
+		if len(h.subs[ticker]) == 0 {
+			delete(h.subs, ticker)
+		}
+	}
+}
+
+// dispatch sends the prices of a new prices event to the subscribers of its
+// ticker.
+func (h *Hub) dispatch(e events.Event) {
+	if e.Type != events.TypeNewPrices {
+		return
+	}
+
+	var p events.NewPrices
+	if err := e.Decode(&p); err != nil {
+		h.log.Error("failed to decode price event", slog.Int64("seq", e.Seq), slog.Any("error", err))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[p.Ticker] {
+		select {
+		case ch <- p.Prices:
+		default:
+			h.log.Warn("dropping prices for slow subscriber", slog.String("ticker", p.Ticker))
+		}
+	}
+}
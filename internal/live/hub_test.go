@@ -0,0 +1,48 @@
+package live
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/events"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+func TestHubDispatch(t *testing.T) {
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	fish, unsubscribe := h.Subscribe("FISH")
+	defer unsubscribe()
+	tank, unsubscribeTank := h.Subscribe("TANK")
+	defer unsubscribeTank()
+
+	prices := []models.StockPrice{
+		{Ticker: "FISH", Timestamp: 1000, Value: models.PriceFromInt(10)},
+		{Ticker: "FISH", Timestamp: 3000, Value: models.PriceFromInt(12)},
+	}
+	payload, err := json.Marshal(events.NewPrices{Ticker: "FISH", From: 1000, To: 3000, Count: 2, Prices: prices})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.dispatch(events.Event{Seq: 1, Type: events.TypeNewPrices, Payload: payload})
+
+	// Only the inserted prices are sent, to the subscribers of their ticker.
+	select {
+	case got := <-fish:
+		if !reflect.DeepEqual(got, prices) {
+			t.Errorf("got %+v, want %+v", got, prices)
+		}
+	default:
+		t.Error("no prices sent to the FISH subscriber")
+	}
+
+	select {
+	case got := <-tank:
+		t.Errorf("TANK subscriber got %+v", got)
+	default:
+	}
+}
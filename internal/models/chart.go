@@ -2,5 +2,5 @@ package models
 
 type ChartData struct {
 	Timestamps []int64
-	Values     []int
+	Values     []Price
 }
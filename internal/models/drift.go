@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// DriftEvent represents a row from drift_events, without the raw payload. An
+// event without issues marks the drift before it as cleared.
+type DriftEvent struct {
+	ID         int64
+	Endpoint   string
+	DetectedAt time.Time
+	Issues     []stox.SchemaIssue
+}
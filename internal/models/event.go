@@ -0,0 +1,14 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event represents a row from events.
+type Event struct {
+	Seq       int64
+	Type      string
+	CreatedAt time.Time
+	Payload   json.RawMessage
+}
@@ -0,0 +1,9 @@
+package models
+
+// PriceGap is a period with no samples for a ticker, bounded by the last
+// sample before it and the first sample after it.
+type PriceGap struct {
+	Ticker string
+	From   int64
+	To     int64
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PricePrecision is the number of decimal places a Price holds.
+const PricePrecision = 2
+
+// priceScale is the number of Price units in a whole ₣.
+const priceScale = 100
+
+// Price is an amount of ₣ in fixed-point, counted in hundredths so averages
+// and other derived prices are exact to PricePrecision decimal places.
+//
+// Prices marshal to JSON as plain numbers and to SQL as whole integers, to fit
+// the INTEGER price columns. Fractional prices, such as averages, cannot be
+// stored and fail to marshal to SQL.
+type Price int64
+
+// PriceFromInt returns a whole ₣ amount, the unit upstream prices come in.
+func PriceFromInt(n int) Price {
+	return Price(n) * priceScale
+}
+
+// PriceFromFloat returns f rounded half away from zero to PricePrecision.
+func PriceFromFloat(f float64) Price {
+	return Price(math.Round(f * priceScale))
+}
+
+// ParsePrice parses a decimal amount such as "-1234.5", rounding half away
+// from zero when it has more than PricePrecision decimal places.
+func ParsePrice(s string) (Price, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+
+	neg := strings.HasPrefix(whole, "-")
+	digits := strings.TrimLeft(whole, "+-")
+	if digits == "" && frac == "" {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+
+	var p int64
+	if digits != "" {
+		n, err := strconv.ParseUint(digits, 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("invalid price %q: %w", s, err)
+		}
+		p = int64(n) * priceScale
+	}
+
+	for i, c := range frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid price %q", s)
+		}
+
+		d := int64(c - '0')
+		switch {
+		case i == 0:
+			p += d * 10
+		case i == 1:
+			p += d
+		case i == 2 && d >= 5:
+			p++
+		}
+	}
+
+	if neg {
+		p = -p
+	}
+	return Price(p), nil
+}
+
+// Float64 returns the price in ₣, for plotting.
+func (p Price) Float64() float64 {
+	return float64(p) / priceScale
+}
+
+// Div returns p divided by n, rounded half away from zero. It panics when n
+// is 0, like integer division.
+func (p Price) Div(n int) Price {
+	return Price(divRound(int64(p), int64(n)))
+}
+
+// AveragePrice returns the mean of prices rounded half away from zero, or 0
+// when there are none.
+func AveragePrice(prices ...Price) Price {
+	if len(prices) == 0 {
+		return 0
+	}
+
+	var sum Price
+	for _, p := range prices {
+		sum += p
+	}
+	return sum.Div(len(prices))
+}
+
+// Percent is a percentage in fixed-point, counted in hundredths of a percent.
+type Percent int64
+
+// PercentChange returns the change from from to to as a percentage of from,
+// rounded half away from zero to two decimal places, and false when from is 0.
+func PercentChange(from, to Price) (Percent, bool) {
+	if from == 0 {
+		return 0, false
+	}
+
+	return Percent(divRound(int64(to-from)*100*100, int64(from))), true
+}
+
+// String formats the percentage with an explicit sign, as in "+1.25%".
+func (p Percent) String() string {
+	sign := "+"
+	if p < 0 {
+		sign, p = "-", -p
+	}
+	return fmt.Sprintf("%s%d.%02d%%", sign, p/100, p%100)
+}
+
+// String formats the price with the ₣ symbol and thousands separators, as in
+// "₣1,234" or "-₣1,234.50". Whole prices are printed without decimals.
+func (p Price) String() string {
+	sign := ""
+	if p < 0 {
+		sign, p = "-", -p
+	}
+
+	s := sign + "₣" + groupThousands(strconv.FormatInt(int64(p/priceScale), 10))
+	if frac := p % priceScale; frac != 0 {
+		s += fmt.Sprintf(".%02d", frac)
+	}
+	return s
+}
+
+// decimal formats the price as a plain decimal number, as in "1234.5".
+func (p Price) decimal() string {
+	sign := ""
+	if p < 0 {
+		sign, p = "-", -p
+	}
+
+	s := sign + strconv.FormatInt(int64(p/priceScale), 10)
+	if frac := p % priceScale; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+	}
+	return s
+}
+
+func (p Price) MarshalJSON() ([]byte, error) {
+	return []byte(p.decimal()), nil
+}
+
+func (p *Price) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+
+	// Numbers in exponent form are rare enough to go through a float.
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid price %q: %w", s, err)
+		}
+		*p = PriceFromFloat(f)
+		return nil
+	}
+
+	parsed, err := ParsePrice(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, returning an error for fractional prices
+// rather than silently rounding them to a whole ₣.
+func (p Price) Value() (driver.Value, error) {
+	if p%priceScale != 0 {
+		return nil, fmt.Errorf("cannot store fractional price %s in a whole ₣ column", p.decimal())
+	}
+	return int64(p / priceScale), nil
+}
+
+// Scan implements sql.Scanner, reading integer columns as whole ₣ and
+// numeric or floating point columns rounded to PricePrecision.
+func (p *Price) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*p = Price(v) * priceScale
+	case float64:
+		*p = PriceFromFloat(v)
+	case []byte:
+		return p.Scan(string(v))
+	case string:
+		parsed, err := ParsePrice(v)
+		if err != nil {
+			return err
+		}
+		*p = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into a price", src)
+	}
+	return nil
+}
+
+// divRound divides a by b rounding half away from zero.
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+
+	q, r := a/b, a%b
+	switch {
+	case r*2 >= b:
+		q++
+	case r*2 <= -b:
+		q--
+	}
+	return q
+}
+
+// groupThousands inserts a comma every three digits from the right.
+func groupThousands(digits string) string {
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
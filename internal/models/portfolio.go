@@ -0,0 +1,10 @@
+package models
+
+// PortfolioValue represents a row from portfolio_values, a user's net worth
+// and leaderboard rank at a point in time.
+type PortfolioValue struct {
+	UserID    string
+	Timestamp int64
+	Value     int64
+	Rank      int
+}
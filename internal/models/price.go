@@ -2,9 +2,9 @@ package models
 
 // StockPrice represents a row from stock_data.
 type StockPrice struct {
-	Ticker    string
-	Timestamp int64
-	Value     int
+	Ticker    string `json:"ticker"`
+	Timestamp int64  `json:"timestamp"`
+	Value     Price  `json:"value"`
 }
 
 // {
@@ -18,8 +18,53 @@ type StockPrice struct {
 type Candle struct {
 	Ticker    string `json:"ticker"`
 	Timestamp int64  `json:"x"`
-	Open      int    `json:"o"`
-	Close     int    `json:"c"`
-	High      int    `json:"h"`
-	Low       int    `json:"l"`
+	Open      Price  `json:"o"`
+	Close     Price  `json:"c"`
+	High      Price  `json:"h"`
+	Low       Price  `json:"l"`
+}
+
+// PriceSpan is a run of a ticker's prices, oldest first, with their bounds, in
+// unix milliseconds, and count.
+type PriceSpan struct {
+	Ticker string       `json:"ticker"`
+	From   int64        `json:"from"`
+	To     int64        `json:"to"`
+	Count  int          `json:"count"`
+	Prices []StockPrice `json:"prices"`
+}
+
+// PriceBucket is the aggregate of a ticker's prices over [Start, End), in
+// unix milliseconds.
+type PriceBucket struct {
+	Ticker  string `json:"ticker"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Open    Price  `json:"open"`
+	High    Price  `json:"high"`
+	Low     Price  `json:"low"`
+	Close   Price  `json:"close"`
+	Avg     Price  `json:"avg"`
+	Samples int    `json:"samples"`
+}
+
+// Candle returns the bucket as a candle plotted at its mid-point.
+func (b PriceBucket) Candle() Candle {
+	return Candle{
+		Ticker:    b.Ticker,
+		Timestamp: b.Start + (b.End-b.Start)/2,
+		Open:      b.Open,
+		Close:     b.Close,
+		High:      b.High,
+		Low:       b.Low,
+	}
+}
+
+// Average returns the bucket average as a price at its mid-point.
+func (b PriceBucket) Average() StockPrice {
+	return StockPrice{
+		Ticker:    b.Ticker,
+		Timestamp: b.Start + (b.End-b.Start)/2,
+		Value:     b.Avg,
+	}
 }
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type ScrapeRunStatus string
+
+const (
+	ScrapeRunSuccess   ScrapeRunStatus = "success"
+	ScrapeRunFailed    ScrapeRunStatus = "failed"
+	ScrapeRunCancelled ScrapeRunStatus = "cancelled"
+)
+
+// ScrapeRun represents a row from scrape_runs, a single run of a scraper job.
+type ScrapeRun struct {
+	ID              int64
+	Job             string
+	StartedAt       time.Time
+	FinishedAt      time.Time
+	Status          ScrapeRunStatus
+	Error           string
+	RowsInserted    int
+	RowsSkipped     int
+	UpstreamLatency time.Duration
+}
+
+// ScrapeFailureStreak is the run of failures a job has had since it last
+// succeeded.
+type ScrapeFailureStreak struct {
+	Job       string
+	Failures  int
+	Since     time.Time
+	LastError string
+}
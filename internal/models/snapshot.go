@@ -0,0 +1,22 @@
+package models
+
+// StockSnapshot represents a row from stock_snapshots, the market state of a
+// ticker as reported by the stocks endpoint at a point in time. Today,
+// LastHour and LastWeek are price changes over those periods.
+type StockSnapshot struct {
+	Ticker           string
+	Timestamp        int64
+	CurrentPrice     Price
+	AveragePrice     Price
+	HighestBuyOrder  Price
+	LowestBuyOrder   Price
+	HighestSellOrder Price
+	LowestSellOrder  Price
+	IpoAvailable     bool
+	IpoPrice         Price
+	IpoSharesLeft    int
+	TotalShares      int
+	Today            Price
+	LastHour         Price
+	LastWeek         Price
+}
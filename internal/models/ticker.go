@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TickerInfo represents a row from tickers_catalog.
+type TickerInfo struct {
+	Ticker      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	IpoPrice    Price
+	TotalShares int
+	Delisted    bool
+}
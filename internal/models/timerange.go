@@ -0,0 +1,243 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeRange is the range charts show when none is requested.
+const DefaultTimeRange = "24h"
+
+// MaxTimeRange bounds how long a range can be, well past the first prices
+// fishtank has.
+const MaxTimeRange = 10 * 365 * 24 * time.Hour
+
+// TimeRange is a span of time in a timezone, inclusive of both ends like the
+// price queries it is passed to.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseTimeRange parses a range relative to now, such as "90m", "1h", "7d",
+// "2w", "3mo" or "1y", a calendar range up to now: "today", "mtd" or "ytd", or
+// an absolute ISO 8601 interval such as "2024-01-01/2024-01-31" or
+// "2024-01-01T09:00:00Z/2024-01-01T17:00:00Z". Dates without a time are days
+// in loc, a date ending the range includes the whole of that day.
+func ParseTimeRange(s string, now time.Time, loc *time.Location) (TimeRange, error) {
+	now = now.In(loc)
+
+	var tr TimeRange
+	switch s = strings.TrimSpace(s); {
+	case s == "":
+		return TimeRange{}, fmt.Errorf("empty time range")
+	case s == "today":
+		tr = TimeRange{From: startOfDay(now), To: now}
+	case s == "mtd":
+		tr = TimeRange{From: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc), To: now}
+	case s == "ytd":
+		tr = TimeRange{From: time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc), To: now}
+	case strings.Contains(s, "/"):
+		start, end, _ := strings.Cut(s, "/")
+
+		from, _, err := parseRangeTime(start, loc)
+		if err != nil {
+			return TimeRange{}, fmt.Errorf("invalid range start: %w", err)
+		}
+		to, dateOnly, err := parseRangeTime(end, loc)
+		if err != nil {
+			return TimeRange{}, fmt.Errorf("invalid range end: %w", err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1).Add(-time.Millisecond)
+		}
+
+		tr = TimeRange{From: from, To: to}
+	default:
+		from, err := parseRelative(s, now)
+		if err != nil {
+			return TimeRange{}, err
+		}
+		tr = TimeRange{From: from, To: now}
+	}
+
+	if err := tr.Validate(); err != nil {
+		return TimeRange{}, err
+	}
+	return tr, nil
+}
+
+// ParseTimeRangeQuery reads a time range from the range and tz query
+// parameters, falling back to DefaultTimeRange in UTC.
+func ParseTimeRangeQuery(q url.Values, now time.Time) (TimeRange, error) {
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return TimeRange{}, fmt.Errorf("invalid timezone %q", tz)
+		}
+	}
+
+	s := q.Get("range")
+	if s == "" {
+		s = DefaultTimeRange
+	}
+
+	return ParseTimeRange(s, now, loc)
+}
+
+// Validate checks the range is not empty nor longer than MaxTimeRange.
+func (tr TimeRange) Validate() error {
+	if !tr.From.Before(tr.To) {
+		return fmt.Errorf("time range must start before it ends")
+	}
+	if tr.Duration() > MaxTimeRange {
+		return fmt.Errorf("time range must be at most %d days", MaxTimeRange/(24*time.Hour))
+	}
+	return nil
+}
+
+// Duration returns the length of the range.
+func (tr TimeRange) Duration() time.Duration {
+	return tr.To.Sub(tr.From)
+}
+
+// Location returns the timezone the range was parsed in.
+func (tr TimeRange) Location() *time.Location {
+	return tr.From.Location()
+}
+
+// Resolution returns the finest standard resolution splitting the range into
+// at most maxPoints buckets, or the coarsest one for ranges too long for any.
+func (tr TimeRange) Resolution(maxPoints int) Resolution {
+	for _, r := range Resolutions {
+		if tr.Buckets(r) <= maxPoints {
+			return r
+		}
+	}
+	return Resolutions[len(Resolutions)-1]
+}
+
+// Buckets returns the number of buckets of r the range spans.
+func (tr TimeRange) Buckets(r Resolution) int {
+	d := r.Duration()
+	return int((tr.Duration() + d - 1) / d)
+}
+
+// Resolution is the width of the buckets prices are aggregated into.
+type Resolution time.Duration
+
+// Resolutions are the standard resolutions, finest first. They divide each
+// other where they can so buckets line up with the rollups.
+var Resolutions = []Resolution{
+	Resolution(time.Minute),
+	Resolution(5 * time.Minute),
+	Resolution(15 * time.Minute),
+	Resolution(30 * time.Minute),
+	Resolution(time.Hour),
+	Resolution(2 * time.Hour),
+	Resolution(4 * time.Hour),
+	Resolution(12 * time.Hour),
+	Resolution(24 * time.Hour),
+	Resolution(7 * 24 * time.Hour),
+}
+
+// ParseResolution parses one of the standard resolutions, such as "15m",
+// "1h", "1d" or "1w".
+func ParseResolution(s string) (Resolution, error) {
+	for _, r := range Resolutions {
+		if r.String() == s {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown resolution %q", s)
+}
+
+// Duration returns the bucket width.
+func (r Resolution) Duration() time.Duration {
+	return time.Duration(r)
+}
+
+// String formats the resolution in its largest whole unit, as in "15m", "4h"
+// or "1w".
+func (r Resolution) String() string {
+	d := r.Duration()
+	switch {
+	case d%(7*24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(7*24*time.Hour))) + "w"
+	case d%(24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	default:
+		return d.String()
+	}
+}
+
+// relativeUnits are the lengths of the units of relative ranges, the
+// shortest month and year for the calendar ones.
+var relativeUnits = map[string]time.Duration{
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 28 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseRelative returns now minus an amount such as "7d". Months and years
+// follow the calendar, the other units are fixed lengths.
+func parseRelative(s string, now time.Time) (time.Time, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return time.Time{}, fmt.Errorf("invalid time range %q", s)
+	}
+
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n <= 0 {
+		return time.Time{}, fmt.Errorf("invalid time range %q", s)
+	}
+
+	unit, ok := relativeUnits[s[i:]]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time range unit in %q", s)
+	}
+
+	// Amounts past MaxTimeRange are rejected before they can overflow a
+	// time.Duration.
+	if n > int(MaxTimeRange/unit) {
+		return time.Time{}, fmt.Errorf("time range must be at most %d days", MaxTimeRange/(24*time.Hour))
+	}
+
+	switch s[i:] {
+	case "mo":
+		return now.AddDate(0, -n, 0), nil
+	case "y":
+		return now.AddDate(-n, 0, 0), nil
+	default:
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+}
+
+// parseRangeTime parses an RFC 3339 time or a date in loc, reporting whether
+// it was a date.
+func parseRangeTime(s string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, true, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected a date or an RFC 3339 time, got %q", s)
+	}
+	return t.In(loc), false, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeRangeRelative(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		from time.Time
+		err  bool
+	}{
+		{in: "90m", from: now.Add(-90 * time.Minute)},
+		{in: "7d", from: now.AddDate(0, 0, -7)},
+		{in: "2w", from: now.AddDate(0, 0, -14)},
+		{in: "3mo", from: time.Date(2023, time.December, 15, 12, 0, 0, 0, time.UTC)},
+		{in: "1y", from: time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{in: "3650d", from: now.AddDate(0, 0, -3650)},
+		{in: "3651d", err: true},
+		{in: "0h", err: true},
+		{in: "5x", err: true},
+		// Amounts that would overflow a time.Duration once multiplied.
+		{in: "213504d", err: true},
+		{in: "30500w", err: true},
+		{in: "2562048h", err: true},
+		{in: "99999999999999999999m", err: true},
+	}
+
+	for _, tt := range tests {
+		tr, err := ParseTimeRange(tt.in, now, time.UTC)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTimeRange(%q) = %v to %v, want an error", tt.in, tr.From, tr.To)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseTimeRange(%q): %v", tt.in, err)
+			continue
+		}
+		if !tr.From.Equal(tt.from) || !tr.To.Equal(now) {
+			t.Errorf("ParseTimeRange(%q) = %v to %v, want %v to %v", tt.in, tr.From, tr.To, tt.from, now)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package prices
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+)
+
+// MaxBuckets bounds how many buckets a single aggregation may produce, so a
+// tiny width over a long range fails rather than exhausting memory.
+const MaxBuckets = 100_000
+
+// Alignment is where bucket boundaries fall.
+type Alignment int
+
+const (
+	// AlignEpoch aligns buckets to multiples of the width since the unix
+	// epoch, like the rollups are.
+	AlignEpoch Alignment = iota
+	// AlignRangeStart starts the first bucket at the start of the range.
+	AlignRangeStart
+	// AlignCalendar aligns day wide buckets to midnight and week wide ones to
+	// Monday midnight in the timezone of the range, so buckets around DST
+	// changes are 23 or 25 hours long.
+	AlignCalendar
+)
+
+// EmptyPolicy is what happens to buckets of the range without prices.
+type EmptyPolicy int
+
+const (
+	// EmptySkip leaves empty buckets out.
+	EmptySkip EmptyPolicy = iota
+	// EmptyCarryForward fills empty buckets with the close of the bucket
+	// before them. Buckets before the first price have nothing to carry and
+	// are left out.
+	EmptyCarryForward
+	// EmptyNull keeps empty buckets with zero prices, so every bucket of the
+	// range is present.
+	EmptyNull
+)
+
+// Options configures Aggregate and the buckets price stores return.
+type Options struct {
+	// Range is the span of prices aggregated, inclusive of both ends.
+	Range models.TimeRange
+	Width time.Duration
+	Align Alignment
+	Empty EmptyPolicy
+}
+
+// alignments are the names of the alignments in query strings.
+var alignments = map[string]Alignment{
+	"epoch":    AlignEpoch,
+	"start":    AlignRangeStart,
+	"calendar": AlignCalendar,
+}
+
+// ParseAlignmentQuery reads the alignment of buckets of width from the align
+// query parameter, one of "epoch", "start" or "calendar". Day and week wide
+// buckets fall back to AlignCalendar, so they follow the timezone of the
+// range, and other buckets to AlignEpoch.
+func ParseAlignmentQuery(q url.Values, width time.Duration) (Alignment, error) {
+	s := q.Get("align")
+	if s == "" {
+		if width == 24*time.Hour || width == 7*24*time.Hour {
+			return AlignCalendar, nil
+		}
+		return AlignEpoch, nil
+	}
+
+	align, ok := alignments[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid alignment %q, expected epoch, start or calendar", s)
+	}
+	if align == AlignCalendar && width != 24*time.Hour && width != 7*24*time.Hour {
+		return 0, fmt.Errorf("calendar alignment needs a resolution of 1d or 1w, got %s", models.Resolution(width))
+	}
+	return align, nil
+}
+
+// Aggregate buckets the prices of a single ticker that fall in the range
+// into the OHLC, rounded average and sample count of every bucket, oldest
+// first. Prices do not need to be sorted, ones with the same timestamp keep
+// their order. Empty buckets have 0 samples.
+func Aggregate(prices []models.StockPrice, opts Options) ([]models.PriceBucket, error) {
+	b, err := NewBucketer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := opts.Range.From.UnixMilli(), opts.Range.To.UnixMilli()
+
+	var in []models.StockPrice
+	for _, p := range prices {
+		if p.Timestamp < from || p.Timestamp > to {
+			continue
+		}
+		if len(in) > 0 && p.Ticker != in[0].Ticker {
+			return nil, fmt.Errorf("prices of more than one ticker: %s and %s", in[0].Ticker, p.Ticker)
+		}
+		in = append(in, p)
+	}
+
+	slices.SortStableFunc(in, func(a, b models.StockPrice) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	var buckets []models.PriceBucket
+	var sum models.Price
+	for _, p := range in {
+		start := b.Start(p.Timestamp)
+
+		if n := len(buckets); n == 0 || buckets[n-1].Start != start {
+			if n == MaxBuckets {
+				return nil, errTooManyBuckets
+			}
+
+			buckets = append(buckets, models.PriceBucket{
+				Ticker: p.Ticker,
+				Start:  start,
+				End:    b.End(start),
+				Open:   p.Value,
+				High:   p.Value,
+				Low:    p.Value,
+			})
+			sum = 0
+		}
+
+		bucket := &buckets[len(buckets)-1]
+		bucket.High = max(bucket.High, p.Value)
+		bucket.Low = min(bucket.Low, p.Value)
+		bucket.Close = p.Value
+		bucket.Samples++
+		sum += p.Value
+		bucket.Avg = sum.Div(bucket.Samples)
+	}
+
+	var ticker string
+	if len(in) > 0 {
+		ticker = in[0].Ticker
+	}
+	return fill(ticker, buckets, opts, b)
+}
+
+// Fill adds the empty buckets of the range the empty policy of opts keeps to
+// buckets, the buckets of opts with prices of ticker, oldest first, as a price
+// store returns them.
+func Fill(ticker string, buckets []models.PriceBucket, opts Options) ([]models.PriceBucket, error) {
+	b, err := NewBucketer(opts)
+	if err != nil {
+		return nil, err
+	}
+	return fill(ticker, buckets, opts, b)
+}
+
+func fill(ticker string, buckets []models.PriceBucket, opts Options, b Bucketer) ([]models.PriceBucket, error) {
+	if opts.Empty == EmptySkip {
+		return buckets, nil
+	}
+
+	filled := make([]models.PriceBucket, 0, len(buckets))
+
+	// next is the start of the bucket following the last one, from which
+	// empty buckets are filled once filling is set.
+	next, filling := b.Start(opts.Range.From.UnixMilli()), opts.Empty == EmptyNull
+
+	add := func(bucket models.PriceBucket) error {
+		if len(filled) == MaxBuckets {
+			return errTooManyBuckets
+		}
+		filled = append(filled, bucket)
+		next, filling = bucket.End, true
+		return nil
+	}
+
+	gap := func(until int64) error {
+		if !filling {
+			return nil
+		}
+
+		for start := next; start < until; start = b.End(start) {
+			empty := models.PriceBucket{Ticker: ticker, Start: start, End: b.End(start)}
+			if opts.Empty == EmptyCarryForward {
+				c := filled[len(filled)-1].Close
+				empty.Open, empty.High, empty.Low, empty.Close, empty.Avg = c, c, c, c, c
+			}
+
+			if err := add(empty); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, bucket := range buckets {
+		if err := gap(bucket.Start); err != nil {
+			return nil, err
+		}
+		if err := add(bucket); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := gap(opts.Range.To.UnixMilli() + 1); err != nil {
+		return nil, err
+	}
+
+	return filled, nil
+}
+
+var errTooManyBuckets = fmt.Errorf("more than %d buckets, use a wider bucket or a shorter range", MaxBuckets)
+
+// Bucketer places unix millisecond timestamps in buckets.
+type Bucketer interface {
+	// Start returns the start of the bucket holding ts.
+	Start(ts int64) int64
+	// End returns the end of the bucket starting at start, which is also the
+	// start of the next one.
+	End(start int64) int64
+}
+
+// NewBucketer returns the Bucketer of the alignment and width of opts,
+// checking they are valid for its range.
+func NewBucketer(opts Options) (Bucketer, error) {
+	if opts.Width < time.Millisecond {
+		return nil, fmt.Errorf("bucket width must be at least 1ms, got %s", opts.Width)
+	}
+	if opts.Range.To.Before(opts.Range.From) {
+		return nil, fmt.Errorf("range must not end before it starts")
+	}
+
+	width := opts.Width.Milliseconds()
+
+	switch opts.Align {
+	case AlignEpoch:
+		return fixedBuckets{width: width}, nil
+	case AlignRangeStart:
+		return fixedBuckets{width: width, offset: opts.Range.From.UnixMilli()}, nil
+	case AlignCalendar:
+		switch opts.Width {
+		case 24 * time.Hour:
+			return calendarBuckets{loc: opts.Range.Location(), days: 1}, nil
+		case 7 * 24 * time.Hour:
+			return calendarBuckets{loc: opts.Range.Location(), days: 7}, nil
+		default:
+			return nil, fmt.Errorf("calendar alignment needs a width of a day or a week, got %s", opts.Width)
+		}
+	default:
+		return nil, fmt.Errorf("unknown alignment %d", opts.Align)
+	}
+}
+
+// Nests reports whether every epoch aligned bucket of width that overlaps the
+// range of opts falls within a single one of its buckets, so prices rolled up
+// into buckets of width can be combined into them.
+func (opts Options) Nests(width time.Duration) bool {
+	b, err := NewBucketer(opts)
+	if err != nil {
+		return false
+	}
+
+	w := width.Milliseconds()
+	switch opts.Align {
+	case AlignEpoch:
+		return opts.Width%width == 0
+	case AlignRangeStart:
+		return opts.Width%width == 0 && opts.Range.From.UnixMilli()%w == 0
+	default:
+		// Calendar buckets are as long as the timezone makes them, so check
+		// every boundary in the range, including the end of the last bucket.
+		to := opts.Range.To.UnixMilli()
+		for start := b.Start(opts.Range.From.UnixMilli()); ; start = b.End(start) {
+			if start%w != 0 {
+				return false
+			}
+			if start > to {
+				return true
+			}
+		}
+	}
+}
+
+// fixedBuckets are width milliseconds wide, starting at offset plus a
+// multiple of width.
+type fixedBuckets struct {
+	width  int64
+	offset int64
+}
+
+func (f fixedBuckets) Start(ts int64) int64 {
+	// Floor rather than truncate so timestamps before the offset land in the
+	// bucket before it.
+	d := ts - f.offset
+	q := d / f.width
+	if d%f.width < 0 {
+		q--
+	}
+	return f.offset + q*f.width
+}
+
+func (f fixedBuckets) End(start int64) int64 {
+	return start + f.width
+}
+
+// calendarBuckets are days long, starting at midnight in loc, on a Monday
+// when they are a week long.
+type calendarBuckets struct {
+	loc  *time.Location
+	days int
+}
+
+func (c calendarBuckets) Start(ts int64) int64 {
+	t := time.UnixMilli(ts).In(c.loc)
+
+	day := t.Day()
+	if c.days == 7 {
+		day -= (int(t.Weekday()) + 6) % 7
+	}
+
+	return time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, c.loc).UnixMilli()
+}
+
+func (c calendarBuckets) End(start int64) int64 {
+	t := time.UnixMilli(start).In(c.loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+c.days, 0, 0, 0, 0, c.loc).UnixMilli()
+}
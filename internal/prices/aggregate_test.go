@@ -0,0 +1,437 @@
+package prices_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
+	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
+)
+
+var (
+	seed = flag.Uint64("seed", 1, "seed of the generated aggregation cases")
+	runs = flag.Int("runs", 2000, "number of generated aggregation cases")
+)
+
+// zones include DST changes by an hour, by half an hour and none at all.
+var zones = []string{"UTC", "America/New_York", "Europe/London", "Australia/Lord_Howe", "Asia/Kathmandu"}
+
+// starts put ranges around DST changes and the unix epoch.
+var starts = []time.Time{
+	time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC),
+	time.Date(2024, time.October, 25, 0, 0, 0, 0, time.UTC),
+	time.Date(2024, time.April, 5, 0, 0, 0, 0, time.UTC),
+	time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC),
+}
+
+var widths = []time.Duration{
+	time.Millisecond,
+	7 * time.Millisecond,
+	time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// testCase is a generated input to Aggregate.
+type testCase struct {
+	prices []models.StockPrice
+	opts   prices.Options
+}
+
+func (tc testCase) String() string {
+	return fmt.Sprintf("%d prices, range %s/%s, width %s, align %d, empty %d",
+		len(tc.prices),
+		tc.opts.Range.From.Format(time.RFC3339Nano), tc.opts.Range.To.Format(time.RFC3339Nano),
+		tc.opts.Width, tc.opts.Align, tc.opts.Empty)
+}
+
+// TestAggregate checks properties of Aggregate that must hold for any input,
+// against prices and options generated from -seed, reporting the first case
+// breaking each property.
+func TestAggregate(t *testing.T) {
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+	ctx := context.Background()
+
+	failed := map[string]bool{}
+	for i := 0; i < *runs; i++ {
+		tc, err := generate(rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buckets, err := prices.Aggregate(tc.prices, tc.opts)
+		if err != nil {
+			if !failed["aggregate"] {
+				failed["aggregate"] = true
+				t.Errorf("run %d: aggregate: %s (%s)", i, err, tc)
+			}
+			continue
+		}
+
+		for _, p := range properties {
+			if failed[p.name] {
+				continue
+			}
+			if err := p.check(ctx, tc, buckets); err != nil {
+				failed[p.name] = true
+				t.Errorf("run %d: %s: %s (%s)", i, p.name, err, tc)
+			}
+		}
+	}
+}
+
+func generate(rng *rand.Rand) (testCase, error) {
+	loc, err := time.LoadLocation(zones[rng.IntN(len(zones))])
+	if err != nil {
+		return testCase{}, fmt.Errorf("failed to load timezone: %w", err)
+	}
+
+	width := widths[rng.IntN(len(widths))]
+
+	// Keep ranges to a few hundred buckets of the width.
+	span := time.Duration(1 + rng.Int64N(int64(300*width)))
+	span = span.Truncate(time.Millisecond) + time.Millisecond
+	from := starts[rng.IntN(len(starts))].Add(time.Duration(rng.Int64N(int64(48 * time.Hour)))).Truncate(time.Millisecond).In(loc)
+	to := from.Add(span)
+
+	align := prices.Alignment(rng.IntN(3))
+	if align == prices.AlignCalendar && width != 24*time.Hour && width != 7*24*time.Hour {
+		align = prices.AlignEpoch
+	}
+
+	// Prices spill either side of the range so filtering is exercised, with
+	// unique timestamps so open and close are well defined.
+	spread := span.Milliseconds()*3/2 + 1
+	n := min(rng.Int64N(200), spread)
+	seen := map[int64]bool{}
+	var ps []models.StockPrice
+	for int64(len(ps)) < n {
+		ts := from.UnixMilli() - span.Milliseconds()/4 + rng.Int64N(spread)
+		if seen[ts] {
+			continue
+		}
+		seen[ts] = true
+		ps = append(ps, models.StockPrice{Ticker: "FISH", Timestamp: ts, Value: models.PriceFromInt(1 + rng.IntN(10000))})
+	}
+
+	return testCase{
+		prices: ps,
+		opts: prices.Options{
+			Range: models.TimeRange{From: from, To: to},
+			Width: width,
+			Align: align,
+			Empty: prices.EmptyPolicy(rng.IntN(3)),
+		},
+	}, nil
+}
+
+type property struct {
+	name  string
+	check func(ctx context.Context, tc testCase, buckets []models.PriceBucket) error
+}
+
+var properties = []property{
+	{"ordered", checkOrdered},
+	{"aligned", checkAligned},
+	{"conserved", checkConserved},
+	{"aggregated", checkAggregated},
+	{"empty policy", checkEmptyPolicy},
+	{"fill", checkFill},
+	{"nests", checkNests},
+	{"concat and average", checkConcatAndAverage},
+}
+
+// inRange returns the prices of the case within its range.
+func inRange(tc testCase) []models.StockPrice {
+	from, to := tc.opts.Range.From.UnixMilli(), tc.opts.Range.To.UnixMilli()
+
+	var in []models.StockPrice
+	for _, p := range tc.prices {
+		if p.Timestamp >= from && p.Timestamp <= to {
+			in = append(in, p)
+		}
+	}
+	return in
+}
+
+// checkOrdered checks buckets are non-empty intervals in order without
+// overlapping.
+func checkOrdered(_ context.Context, _ testCase, buckets []models.PriceBucket) error {
+	for i, b := range buckets {
+		if b.Start >= b.End {
+			return fmt.Errorf("bucket %d ends at %d before it starts at %d", i, b.End, b.Start)
+		}
+		if i > 0 && buckets[i-1].End > b.Start {
+			return fmt.Errorf("bucket %d starts at %d before bucket %d ends at %d", i, b.Start, i-1, buckets[i-1].End)
+		}
+	}
+	return nil
+}
+
+// checkAligned checks bucket boundaries fall where the alignment puts them.
+func checkAligned(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	width := tc.opts.Width.Milliseconds()
+	loc := tc.opts.Range.Location()
+
+	for i, b := range buckets {
+		switch tc.opts.Align {
+		case prices.AlignEpoch:
+			if b.Start%width != 0 || b.End-b.Start != width {
+				return fmt.Errorf("bucket %d [%d, %d) is not aligned to the epoch", i, b.Start, b.End)
+			}
+		case prices.AlignRangeStart:
+			if (b.Start-tc.opts.Range.From.UnixMilli())%width != 0 || b.End-b.Start != width {
+				return fmt.Errorf("bucket %d [%d, %d) is not aligned to the range start", i, b.Start, b.End)
+			}
+		case prices.AlignCalendar:
+			start, end := time.UnixMilli(b.Start).In(loc), time.UnixMilli(b.End).In(loc)
+			days := int(tc.opts.Width / (24 * time.Hour))
+
+			if !isMidnight(start) || !isMidnight(end) {
+				return fmt.Errorf("bucket %d %s to %s is not aligned to midnight", i, start, end)
+			}
+			if days == 7 && start.Weekday() != time.Monday {
+				return fmt.Errorf("bucket %d starts on a %s", i, start.Weekday())
+			}
+			if next := time.Date(start.Year(), start.Month(), start.Day()+days, 0, 0, 0, 0, loc); !next.Equal(end) {
+				return fmt.Errorf("bucket %d starting %s ends %s, want %s", i, start, end, next)
+			}
+		}
+	}
+	return nil
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// checkConserved checks every price in range is counted in the bucket holding
+// it and prices out of range are not counted at all.
+func checkConserved(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	in := inRange(tc)
+
+	total := 0
+	for _, b := range buckets {
+		total += b.Samples
+	}
+	if total != len(in) {
+		return fmt.Errorf("%d samples in buckets, want %d", total, len(in))
+	}
+
+	for _, p := range in {
+		found := false
+		for _, b := range buckets {
+			if p.Timestamp >= b.Start && p.Timestamp < b.End && b.Samples > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("price at %d is in no bucket", p.Timestamp)
+		}
+	}
+	return nil
+}
+
+// checkAggregated recomputes the OHLC, average and sample count of every
+// bucket with prices.
+func checkAggregated(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	in := inRange(tc)
+
+	for i, b := range buckets {
+		if b.Samples == 0 {
+			continue
+		}
+
+		want := models.PriceBucket{Ticker: b.Ticker, Start: b.Start, End: b.End}
+		var values []models.Price
+		var first, last int64
+		for _, p := range in {
+			if p.Timestamp < b.Start || p.Timestamp >= b.End {
+				continue
+			}
+
+			if len(values) == 0 || p.Timestamp < first {
+				first, want.Open = p.Timestamp, p.Value
+			}
+			if len(values) == 0 || p.Timestamp > last {
+				last, want.Close = p.Timestamp, p.Value
+			}
+			if len(values) == 0 || p.Value > want.High {
+				want.High = p.Value
+			}
+			if len(values) == 0 || p.Value < want.Low {
+				want.Low = p.Value
+			}
+			values = append(values, p.Value)
+		}
+		want.Samples = len(values)
+		want.Avg = models.AveragePrice(values...)
+
+		if !reflect.DeepEqual(b, want) {
+			return fmt.Errorf("bucket %d is %+v, want %+v", i, b, want)
+		}
+		if b.Avg < b.Low || b.Avg > b.High {
+			return fmt.Errorf("bucket %d average %s is outside %s to %s", i, b.Avg, b.Low, b.High)
+		}
+	}
+	return nil
+}
+
+// checkEmptyPolicy checks which empty buckets are present and what they hold.
+func checkEmptyPolicy(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	from, to := tc.opts.Range.From.UnixMilli(), tc.opts.Range.To.UnixMilli()
+
+	if tc.opts.Empty == prices.EmptySkip {
+		for i, b := range buckets {
+			if b.Samples == 0 {
+				return fmt.Errorf("bucket %d is empty", i)
+			}
+		}
+		return nil
+	}
+
+	if len(buckets) == 0 {
+		if tc.opts.Empty == prices.EmptyNull || len(inRange(tc)) > 0 {
+			return fmt.Errorf("no buckets")
+		}
+		return nil
+	}
+
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i-1].End != buckets[i].Start {
+			return fmt.Errorf("gap between bucket %d and %d", i-1, i)
+		}
+	}
+
+	if last := buckets[len(buckets)-1]; last.Start > to || last.End <= to {
+		return fmt.Errorf("last bucket [%d, %d) does not hold the range end %d", last.Start, last.End, to)
+	}
+
+	switch tc.opts.Empty {
+	case prices.EmptyNull:
+		if first := buckets[0]; first.Start > from || first.End <= from {
+			return fmt.Errorf("first bucket [%d, %d) does not hold the range start %d", first.Start, first.End, from)
+		}
+
+		for i, b := range buckets {
+			if b.Samples == 0 && (b.Open != 0 || b.High != 0 || b.Low != 0 || b.Close != 0 || b.Avg != 0) {
+				return fmt.Errorf("empty bucket %d has prices %+v", i, b)
+			}
+		}
+	case prices.EmptyCarryForward:
+		if buckets[0].Samples == 0 {
+			return fmt.Errorf("first bucket is empty")
+		}
+
+		for i, b := range buckets {
+			if b.Samples > 0 {
+				continue
+			}
+			c := buckets[i-1].Close
+			if b.Open != c || b.High != c || b.Low != c || b.Close != c || b.Avg != c {
+				return fmt.Errorf("empty bucket %d %+v does not carry the close %s", i, b, c)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFill checks filling the buckets with prices gives the same buckets, as
+// price stores rely on.
+func checkFill(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	skip := tc.opts
+	skip.Empty = prices.EmptySkip
+
+	withPrices, err := prices.Aggregate(tc.prices, skip)
+	if err != nil {
+		return err
+	}
+
+	filled, err := prices.Fill("FISH", withPrices, tc.opts)
+	if err != nil {
+		return err
+	}
+
+	// Without any prices Aggregate cannot tell the ticker of empty buckets.
+	want := slices.Clone(buckets)
+	for i := range want {
+		want[i].Ticker = "FISH"
+	}
+
+	if len(filled) != len(want) || (len(want) > 0 && !reflect.DeepEqual(filled, want)) {
+		return fmt.Errorf("filled %d buckets %+v, aggregated %d %+v", len(filled), filled, len(want), want)
+	}
+	return nil
+}
+
+// rollupWidths are the widths of the rollups price stores read from.
+var rollupWidths = []time.Duration{time.Minute, 15 * time.Minute, time.Hour, 24 * time.Hour}
+
+// checkNests checks the epoch aligned buckets of the widths the options nest
+// lie within the bucket of every price in them.
+func checkNests(_ context.Context, tc testCase, buckets []models.PriceBucket) error {
+	for _, width := range rollupWidths {
+		if !tc.opts.Nests(width) {
+			continue
+		}
+
+		w := width.Milliseconds()
+		for _, p := range inRange(tc) {
+			start := p.Timestamp - p.Timestamp%w
+			if p.Timestamp%w < 0 {
+				start -= w
+			}
+
+			for _, b := range buckets {
+				if p.Timestamp >= b.Start && p.Timestamp < b.End && (start < b.Start || start+w > b.End) {
+					return fmt.Errorf("%s bucket at %d is not within [%d, %d)", width, start, b.Start, b.End)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkConcatAndAverage checks averaging over the range returns at most the
+// requested number of prices, all of the ticker, in order and plotted no
+// further out than the mid-point of a bucket holding the end of the range.
+func checkConcatAndAverage(_ context.Context, tc testCase, _ []models.PriceBucket) error {
+	numPrices := 1 + len(tc.prices)%50
+
+	averaged, err := prices.ConcatAndAverage(tc.prices, numPrices, tc.opts.Range.From, tc.opts.Range.To)
+	if err != nil {
+		return err
+	}
+
+	if len(averaged) > numPrices {
+		return fmt.Errorf("%d prices, want at most %d", len(averaged), numPrices)
+	}
+	if len(averaged) == 0 && len(inRange(tc)) > 0 {
+		return fmt.Errorf("no prices")
+	}
+
+	from, to := tc.opts.Range.From.UnixMilli(), tc.opts.Range.To.UnixMilli()
+	width := (to - from + int64(numPrices)) / int64(numPrices)
+
+	for i, p := range averaged {
+		if p.Ticker != "FISH" {
+			return fmt.Errorf("price %d has ticker %q", i, p.Ticker)
+		}
+		if i > 0 && p.Timestamp <= averaged[i-1].Timestamp {
+			return fmt.Errorf("price %d at %d is not after price %d", i, p.Timestamp, i-1)
+		}
+		if p.Timestamp < from || p.Timestamp > to+width/2 {
+			return fmt.Errorf("price %d at %d is outside the range", i, p.Timestamp)
+		}
+	}
+	return nil
+}
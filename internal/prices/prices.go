@@ -7,63 +7,37 @@ import (
 	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 )
 
-// ConcatAndAverage processes the raw prices and averages them into specified intervals.
+// ConcatAndAverage averages the prices between from and to inclusive into at
+// most numPrices buckets aligned to from, each plotted at its mid-point.
+// Buckets without prices are left out.
 func ConcatAndAverage(prices []models.StockPrice, numPrices int, from, to time.Time) ([]models.StockPrice, error) {
-	// Sanity check for numPrices
 	if numPrices <= 0 {
 		return nil, fmt.Errorf("numPrices must be greater than 0")
 	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("range must not end before it starts")
+	}
 
-	// Calculate the total duration in milliseconds
-	duration := to.Sub(from).Milliseconds()
-
-	// Calculate the time interval between each price in milliseconds
-	interval := duration / int64(numPrices)
-
-	var processedPrices []models.StockPrice
-	var currentIntervalStart int64
-	var priceSum, priceCount int
-
-	// Iterate through the raw prices and group them by intervals
-	for _, sp := range prices {
-		ts := int64(sp.Timestamp)
-
-		// If the timestamp is within the current interval, accumulate the price
-		if ts >= currentIntervalStart && ts < currentIntervalStart+interval {
-			priceSum += sp.Value
-			priceCount++
-		} else {
-			// If the interval is complete, compute the average for the interval
-			if priceCount > 0 {
-				averagePrice := priceSum / priceCount
-				processedPrices = append(processedPrices, models.StockPrice{
-					Ticker:    sp.Ticker,
-					Timestamp: currentIntervalStart + interval/2, // Midpoint of the interval
-					Value:     averagePrice,
-				})
-			}
-
-			// Reset for the new interval
-			currentIntervalStart = ts - ts%interval
-			priceSum = sp.Value
-			priceCount = 1
-		}
-
-		// Stop if we've picked the required number of prices
-		if len(processedPrices) == numPrices {
-			break
-		}
+	// Both ends are included, so the range spans one more millisecond than
+	// to - from. Rounding the width up keeps the bucket holding to within
+	// numPrices.
+	span := to.Sub(from).Milliseconds() + 1
+	width := (span + int64(numPrices) - 1) / int64(numPrices)
+
+	buckets, err := Aggregate(prices, Options{
+		Range: models.TimeRange{From: from, To: to},
+		Width: time.Duration(width) * time.Millisecond,
+		Align: AlignRangeStart,
+		Empty: EmptySkip,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// If there are remaining prices after looping through all rows, compute the last average
-	if priceCount > 0 && len(processedPrices) < numPrices {
-		averagePrice := priceSum / priceCount
-		processedPrices = append(processedPrices, models.StockPrice{
-			Ticker:    "Last",
-			Timestamp: currentIntervalStart + interval/2, // Midpoint of the last interval
-			Value:     averagePrice,
-		})
+	averaged := make([]models.StockPrice, 0, len(buckets))
+	for _, b := range buckets {
+		averaged = append(averaged, b.Average())
 	}
 
-	return processedPrices, nil
+	return averaged, nil
 }
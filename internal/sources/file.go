@@ -0,0 +1,123 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+const (
+	RecordPrices      = "prices"
+	RecordStocks      = "stocks"
+	RecordLeaderboard = "leaderboard"
+)
+
+// Record is a line of a JSONL source file. Data holds the payload exactly as
+// the upstream endpoint returns it, Range is only set for prices records.
+type Record struct {
+	Kind  string             `json:"kind"`
+	Range stox.PriceInterval `json:"range,omitempty"`
+	Data  json.RawMessage    `json:"data"`
+}
+
+// File serves recorded payloads from a JSONL file. Every call returns the next
+// record of the requested kind in file order, wrapping around at the end, so a
+// run over the same file always sees the same sequence.
+type File struct {
+	mu      sync.Mutex
+	records map[string][]json.RawMessage
+	next    map[string]int
+}
+
+// NewFile loads every record of a JSONL file.
+func NewFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer f.Close()
+
+	src := &File{
+		records: map[string][]json.RawMessage{},
+		next:    map[string]int{},
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d: %w", line, err)
+		}
+
+		key := recordKey(r.Kind, r.Range)
+		src.records[key] = append(src.records[key], r.Data)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	return src, nil
+}
+
+func recordKey(kind string, interval stox.PriceInterval) string {
+	if interval == "" {
+		return kind
+	}
+	return kind + ":" + string(interval)
+}
+
+func (f *File) nextRecord(kind string, interval stox.PriceInterval) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := recordKey(kind, interval)
+	records := f.records[key]
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no %s records in source file", key)
+	}
+
+	i := f.next[key]
+	f.next[key] = (i + 1) % len(records)
+	return records[i], nil
+}
+
+func (f *File) GetPriceData(ctx context.Context, interval stox.PriceInterval) (stox.PriceData, error) {
+	raw, err := f.nextRecord(RecordPrices, interval)
+	if err != nil {
+		return stox.PriceData{}, err
+	}
+
+	data, _, err := stox.DecodePriceData(raw)
+	return data, err
+}
+
+func (f *File) GetStocks(ctx context.Context) (*stox.StocksResponse, error) {
+	raw, err := f.nextRecord(RecordStocks, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := stox.DecodeStocks(raw)
+	return resp, err
+}
+
+func (f *File) GetPortfolioValues(ctx context.Context) (*stox.PortfolioValuesResponse, error) {
+	raw, err := f.nextRecord(RecordLeaderboard, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := stox.DecodePortfolioValues(raw)
+	return resp, err
+}
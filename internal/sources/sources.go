@@ -0,0 +1,40 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/cacher"
+	"github.com/JamesTiberiusKirk/fishstox/internal/config"
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+const (
+	SourceFishtank  = "fishtank"
+	SourceFile      = "file"
+	SourceSynthetic = "synthetic"
+)
+
+// New creates the price source selected by PRICE_SOURCE.
+func New(cfg config.Config) (cacher.PriceSource, error) {
+	switch cfg.PriceSource {
+	case SourceFishtank, "":
+		return stox.NewClient(
+			&http.Client{Timeout: cfg.StoxTimeout},
+			cfg.StoxBaseURL,
+			http.Header{"User-Agent": {cfg.StoxUserAgent}},
+			stox.RetryPolicy{
+				MaxAttempts: cfg.StoxMaxAttempts,
+				BaseDelay:   cfg.StoxRetryBaseDelay,
+				MaxDelay:    cfg.StoxRetryMaxDelay,
+			},
+			stox.NewLimiter(cfg.StoxRateLimit, cfg.StoxRateBurst),
+		), nil
+	case SourceFile:
+		return NewFile(cfg.PriceSourceFile)
+	case SourceSynthetic:
+		return NewSynthetic(cfg.SyntheticSeed, cfg.SyntheticTickers), nil
+	default:
+		return nil, fmt.Errorf("unknown price source %q", cfg.PriceSource)
+	}
+}
@@ -0,0 +1,252 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/stox"
+)
+
+// syntheticEpoch is the first day of synthetic price history.
+var syntheticEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	minutesPerDay = 24 * 60
+
+	// minuteVolatility is the maximum relative price move per minute.
+	minuteVolatility = 0.002
+
+	syntheticUsers = 25
+)
+
+var syntheticClans = []string{"FISH", "TANK", "BAIT"}
+
+// Synthetic generates a deterministic random walk per ticker. The price at any
+// minute depends only on the seed, the ticker and the time, so every range
+// and every run agree with each other, which makes it suitable for offline
+// runs and CI.
+type Synthetic struct {
+	seed    uint64
+	tickers []string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	dayOpens  map[string][]float64
+	baseCache map[string]float64
+}
+
+func NewSynthetic(seed uint64, tickers []string) *Synthetic {
+	return &Synthetic{
+		seed:      seed,
+		tickers:   tickers,
+		now:       time.Now,
+		dayOpens:  map[string][]float64{},
+		baseCache: map[string]float64{},
+	}
+}
+
+// rangeSpec is the window and sample step each price range is served at.
+func rangeSpec(interval stox.PriceInterval, now time.Time) (time.Time, time.Duration, error) {
+	switch interval {
+	case stox.PriceIntervalHour:
+		return now.Add(-time.Hour), time.Minute, nil
+	case stox.PriceIntervalDay:
+		return now.Add(-24 * time.Hour), 5 * time.Minute, nil
+	case stox.PriceIntervalWeek:
+		return now.Add(-7 * 24 * time.Hour), time.Hour, nil
+	case stox.PriceIntervalMax:
+		return syntheticEpoch, 24 * time.Hour, nil
+	default:
+		return time.Time{}, 0, fmt.Errorf("unknown price interval %q", interval)
+	}
+}
+
+func (s *Synthetic) GetPriceData(ctx context.Context, interval stox.PriceInterval) (stox.PriceData, error) {
+	now := s.now().UTC()
+	from, step, err := rangeSpec(interval, now)
+	if err != nil {
+		return stox.PriceData{}, err
+	}
+
+	if from.Before(syntheticEpoch) {
+		from = syntheticEpoch
+	}
+	from = from.Truncate(step)
+
+	data := stox.PriceData{Prices: map[string]map[string]int{}}
+	for _, ticker := range s.tickers {
+		series := map[string]int{}
+		for t := from; !t.After(now); t = t.Add(step) {
+			series[strconv.FormatInt(t.UnixMilli(), 10)] = s.priceAt(ticker, t)
+		}
+		data.Prices[ticker] = series
+	}
+
+	return data, nil
+}
+
+func (s *Synthetic) GetStocks(ctx context.Context) (*stox.StocksResponse, error) {
+	now := s.now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	daysListed := int(now.Sub(syntheticEpoch) / (24 * time.Hour))
+
+	resp := &stox.StocksResponse{}
+	for _, ticker := range s.tickers {
+		cur := s.priceAt(ticker, now)
+		open := s.priceAt(ticker, day)
+		h := mix(s.seed, hashString(ticker), 1)
+
+		totalShares := 1000 + int(h%9000)
+		sharesLeft := max(0, totalShares/2-daysListed*10)
+		ipoPrice := int(math.Round(s.base(ticker)))
+
+		resp.Stocks = append(resp.Stocks, stox.Stock{
+			AveragePrice:     (open + cur) / 2,
+			CurrentPrice:     cur,
+			HighestBuyOrder:  cur * 99 / 100,
+			LowestBuyOrder:   cur * 90 / 100,
+			LowestSellOrder:  cur * 101 / 100,
+			HighestSellOrder: cur * 110 / 100,
+			IpoAvailable:     sharesLeft > 0,
+			IpoPrice:         ipoPrice,
+			IpoSharesLeft:    sharesLeft,
+			LastHour:         cur - s.priceAt(ticker, now.Add(-time.Hour)),
+			LastWeek:         cur - s.priceAt(ticker, now.Add(-7*24*time.Hour)),
+			TickerSymbol:     ticker,
+			Today:            cur - open,
+			TotalShares:      totalShares,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *Synthetic) GetPortfolioValues(ctx context.Context) (*stox.PortfolioValuesResponse, error) {
+	now := s.now().UTC()
+
+	resp := &stox.PortfolioValuesResponse{}
+	for i := range syntheticUsers {
+		id := fmt.Sprintf("synthetic-user-%02d", i+1)
+		h := mix(s.seed, hashString(id), 2)
+		clan := syntheticClans[h%uint64(len(syntheticClans))]
+
+		resp.PortfolioValues = append(resp.PortfolioValues, stox.PortfolioValue{
+			UserID:         id,
+			PortfolioValue: s.priceAt("user:"+id, now) * 100,
+			Profile: stox.Profile{
+				ID:          id,
+				DisplayName: fmt.Sprintf("Trader%02d", i+1),
+				Color:       fmt.Sprintf("#%06x", h&0xffffff),
+				XP:          int(h % 100000),
+				Clan:        stox.Clan{Tag: clan, Rank: int(hashString(clan)%10) + 1},
+				Joined:      syntheticEpoch.Add(time.Duration(h%365) * 24 * time.Hour).UnixMilli(),
+				Medals:      map[string]int{},
+			},
+		})
+	}
+
+	return resp, nil
+}
+
+// priceAt returns the price of a ticker at the minute containing t.
+func (s *Synthetic) priceAt(ticker string, t time.Time) int {
+	if t.Before(syntheticEpoch) {
+		t = syntheticEpoch
+	}
+
+	elapsed := t.Sub(syntheticEpoch)
+	day := int(elapsed / (24 * time.Hour))
+	minute := int(elapsed%(24*time.Hour)) / int(time.Minute)
+
+	price := s.dayOpen(ticker, day)
+	for m := range minute {
+		price = s.step(ticker, day, m, price)
+	}
+
+	return max(1, int(math.Round(price)))
+}
+
+// dayOpen returns the opening price of a day, which is the close of the walk
+// over every minute of the previous day.
+func (s *Synthetic) dayOpen(ticker string, day int) float64 {
+	s.mu.Lock()
+	opens, ok := s.dayOpens[ticker]
+	s.mu.Unlock()
+
+	if !ok || len(opens) <= day {
+		// The cached slice is shared with other jobs, so it is extended in
+		// a copy rather than in place.
+		if ok {
+			opens = slices.Clone(opens)
+		} else {
+			opens = []float64{s.base(ticker)}
+		}
+
+		for d := len(opens) - 1; d < day; d++ {
+			price := opens[d]
+			for m := range minutesPerDay {
+				price = s.step(ticker, d, m, price)
+			}
+			opens = append(opens, price)
+		}
+
+		s.mu.Lock()
+		if len(opens) > len(s.dayOpens[ticker]) {
+			s.dayOpens[ticker] = opens
+		}
+		s.mu.Unlock()
+	}
+
+	return opens[day]
+}
+
+// step moves the price by one minute of the walk.
+func (s *Synthetic) step(ticker string, day, minute int, price float64) float64 {
+	h := mix(s.seed, hashString(ticker), uint64(day), uint64(minute))
+	move := (unit(h)*2 - 1) * minuteVolatility
+	return math.Max(1, price*(1+move))
+}
+
+// base is the price of a ticker on the first day of history.
+func (s *Synthetic) base(ticker string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.baseCache[ticker]; ok {
+		return b
+	}
+
+	b := 100 + float64(mix(s.seed, hashString(ticker))%900)
+	s.baseCache[ticker] = b
+	return b
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix hashes its inputs with splitmix64.
+func mix(xs ...uint64) uint64 {
+	h := uint64(0x9e3779b97f4a7c15)
+	for _, x := range xs {
+		h ^= x
+		h += 0x9e3779b97f4a7c15
+		h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+		h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return h
+}
+
+// unit maps a hash to [0, 1).
+func unit(h uint64) float64 {
+	return float64(h>>11) / (1 << 53)
+}
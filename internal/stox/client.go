@@ -1,18 +1,177 @@
 package stox
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 const (
-	stoxPricesEndpoint      = "https://api.fishtank.live/v1/stocks/prices?range="
-	stoxLeaderBoardEndpoint = "https://api.fishtank.live/v1/stocks/leader-board"
-	stoxStocksEndpoint      = "https://api.fishtank.live/v1/stocks"
+	DefaultBaseURL   = "https://api.fishtank.live"
+	DefaultUserAgent = "fishstox"
 )
 
+// Endpoint paths, also used to tag drift events and archived payloads.
+const (
+	PricesEndpoint      = "/v1/stocks/prices"
+	LeaderBoardEndpoint = "/v1/stocks/leader-board"
+	StocksEndpoint      = "/v1/stocks"
+)
+
+// Client talks to the fishtank stox API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+	retry      RetryPolicy
+	limiter    *Limiter
+	onDrift    func(context.Context, Drift)
+	onPayload  func(context.Context, Payload)
+}
+
+// Payload is a raw successful response body along with its fetch metadata.
+type Payload struct {
+	Endpoint  string
+	URL       string
+	FetchedAt time.Time
+	Latency   time.Duration
+	Body      []byte
+}
+
+// NewClient creates a stox API client. An empty baseURL defaults to the public
+// fishtank API and a nil httpClient defaults to http.DefaultClient, so timeouts
+// should be configured on the http.Client passed in. The headers are sent with
+// every request, with a default User-Agent if none is provided. Failed requests
+// are retried according to retry, DefaultRetryPolicy when it is the zero
+// value, and every attempt waits on limiter, which may be nil.
+func NewClient(
+	httpClient *http.Client,
+	baseURL string,
+	headers http.Header,
+	retry RetryPolicy,
+	limiter *Limiter,
+) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	h := headers.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	if h.Get("User-Agent") == "" {
+		h.Set("User-Agent", DefaultUserAgent)
+	}
+
+	if retry == (RetryPolicy{}) {
+		retry = DefaultRetryPolicy
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+		headers:    h,
+		retry:      retry,
+		limiter:    limiter,
+	}
+}
+
+// SetDriftHandler registers fn to be called with the schema issues of every
+// decoded payload, with none when it matches the expected schema so a handler
+// can tell when drift clears.
+func (c *Client) SetDriftHandler(fn func(ctx context.Context, d Drift)) {
+	c.onDrift = fn
+}
+
+// SetPayloadHandler registers fn to be called with the raw body of every
+// successful response, before it is decoded.
+func (c *Client) SetPayloadHandler(fn func(ctx context.Context, p Payload)) {
+	c.onPayload = fn
+}
+
+func (c *Client) reportDrift(ctx context.Context, endpoint string, body []byte, issues []SchemaIssue) {
+	if c.onDrift == nil {
+		return
+	}
+
+	c.onDrift(ctx, Drift{
+		Endpoint:  endpoint,
+		FetchedAt: time.Now(),
+		Issues:    issues,
+		Payload:   body,
+	})
+}
+
+// get performs a GET request against the given endpoint, retrying transient
+// failures, and returns the body of a successful response.
+func (c *Client) get(ctx context.Context, endpoint string, query url.Values) ([]byte, error) {
+	u := c.baseURL + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	start := time.Now()
+	body, err := c.withRetry(ctx, func() ([]byte, error) {
+		return c.do(ctx, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.onPayload != nil {
+		c.onPayload(ctx, Payload{
+			Endpoint:  endpoint,
+			URL:       u,
+			FetchedAt: start,
+			Latency:   time.Since(start),
+			Body:      body,
+		})
+	}
+
+	return body, nil
+}
+
+// do performs a single GET request.
+func (c *Client) do(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header = c.headers.Clone()
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain so the connection can be reused by the retry.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return body, nil
+}
+
 type PriceData struct {
 	//		   ticker: timestamp:price
 	Prices map[string]map[string]int `json:"prices"`
@@ -27,29 +186,36 @@ const (
 	PriceIntervalHour PriceInterval = "hour"
 )
 
-func GetPriceData(interval PriceInterval) (PriceData, error) {
-	var data PriceData
-	resp, err := http.Get(stoxPricesEndpoint + string(interval))
+func (c *Client) GetPriceData(ctx context.Context, interval PriceInterval) (PriceData, error) {
+	body, err := c.get(ctx, PricesEndpoint, url.Values{"range": {string(interval)}})
 	if err != nil {
-		return data, fmt.Errorf("failed to fetch data: %w", err)
+		return PriceData{}, fmt.Errorf("failed to fetch data: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return data, fmt.Errorf("bad status: %s", resp.Status)
+	data, issues, err := DecodePriceData(body)
+	c.reportDrift(ctx, PricesEndpoint, body, issues)
+	if err != nil {
+		return data, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return data, nil
+}
+
+// DecodePriceData decodes a raw prices payload, dropping points with invalid
+// timestamps or negative prices, and returns any schema issues found.
+func DecodePriceData(body []byte) (PriceData, []SchemaIssue, error) {
+	var data PriceData
+	issues, err := decode(body, &data)
 	if err != nil {
-		return data, fmt.Errorf("failed to read response body: %w", err)
+		return data, issues, err
 	}
 
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return data, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	priceIssues, invalid := validatePrices(data)
+	for _, p := range invalid {
+		delete(data.Prices[p.ticker], p.timestamp)
 	}
 
-	return data, nil
+	return data, append(issues, priceIssues...), nil
 }
 
 type Stock struct {
@@ -76,28 +242,31 @@ type StocksResponse struct {
 	Stocks []Stock `json:"stocks"`
 }
 
-func GetStocks() (*StocksResponse, error) {
-	resp, err := http.Get(stoxStocksEndpoint)
+func (c *Client) GetStocks(ctx context.Context) (*StocksResponse, error) {
+	body, err := c.get(ctx, StocksEndpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch stocks: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
-	}
 
-	body, err := io.ReadAll(resp.Body)
+	stocksResp, issues, err := DecodeStocks(body)
+	c.reportDrift(ctx, StocksEndpoint, body, issues)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
+	return stocksResp, nil
+}
+
+// DecodeStocks decodes a raw stocks payload and returns any schema issues
+// found.
+func DecodeStocks(body []byte) (*StocksResponse, []SchemaIssue, error) {
 	var stocksResp StocksResponse
-	if err := json.Unmarshal(body, &stocksResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	issues, err := decode(body, &stocksResp)
+	if err != nil {
+		return nil, issues, err
 	}
 
-	return &stocksResp, nil
+	return &stocksResp, issues, nil
 }
 
 type Clan struct {
@@ -137,26 +306,29 @@ type PortfolioValuesResponse struct {
 	PortfolioValues []PortfolioValue `json:"portfolioValues"`
 }
 
-func GetPortfolioValues() (*PortfolioValuesResponse, error) {
-	resp, err := http.Get(stoxLeaderBoardEndpoint)
+func (c *Client) GetPortfolioValues(ctx context.Context) (*PortfolioValuesResponse, error) {
+	body, err := c.get(ctx, LeaderBoardEndpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch portfolio values: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
-	}
 
-	body, err := io.ReadAll(resp.Body)
+	pvr, issues, err := DecodePortfolioValues(body)
+	c.reportDrift(ctx, LeaderBoardEndpoint, body, issues)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
+	return pvr, nil
+}
+
+// DecodePortfolioValues decodes a raw leaderboard payload and returns any
+// schema issues found.
+func DecodePortfolioValues(body []byte) (*PortfolioValuesResponse, []SchemaIssue, error) {
 	var pvr PortfolioValuesResponse
-	if err := json.Unmarshal(body, &pvr); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	issues, err := decode(body, &pvr)
+	if err != nil {
+		return nil, issues, err
 	}
 
-	return &pvr, nil
+	return &pvr, issues, nil
 }
@@ -0,0 +1,114 @@
+package stox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket shared by every request made through a Client, so
+// a scrape that hits several endpoints back to back stays within one budget.
+// A nil *Limiter never blocks.
+type Limiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter creates a limiter allowing rate requests per second with bursts of
+// up to burst requests. A rate of zero or less disables limiting.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	delay := l.reserve()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Pause stops handing out tokens for d, used when the upstream tells us to back
+// off with a Retry-After header.
+func (l *Limiter) Pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// reserve takes a token, letting the bucket go negative, and returns how long
+// the caller has to wait before using it.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	if pause := l.pausedUntil.Sub(now); pause > delay {
+		delay = pause
+	}
+
+	return delay
+}
+
+// cancel returns a reserved token that was never used.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
@@ -0,0 +1,135 @@
+package stox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on every
+	// following attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than this makes the
+	// request fail instead of waiting.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created with a zero RetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// StatusError is returned when the upstream responds with a non 200 status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+// backoff returns a full jitter exponential backoff for the given retry number.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay << retry
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d) + 1
+}
+
+// retryable reports whether a failed request is worth trying again. Only
+// the caller's context ending stops retries, an attempt timing out on the
+// http.Client timeout is retried like any other transport failure.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+
+	// Anything else is a transport level failure.
+	return true
+}
+
+// parseRetryAfter parses a Retry-After header in either its delay-seconds or
+// HTTP date form.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+
+	return 0
+}
+
+// withRetry runs do until it succeeds, the error is not retryable, or the
+// policy runs out of attempts. Every attempt waits on the shared limiter.
+func (c *Client) withRetry(ctx context.Context, do func() ([]byte, error)) ([]byte, error) {
+	attempts := max(c.retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		body, err := do()
+		if err == nil {
+			return body, nil
+		}
+
+		if attempt >= attempts || !retryable(ctx, err) {
+			if attempt > 1 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		delay := c.retry.backoff(attempt - 1)
+
+		var se *StatusError
+		if errors.As(err, &se) && se.RetryAfter > 0 {
+			if se.RetryAfter > c.retry.MaxDelay {
+				return nil, fmt.Errorf("retry after %s exceeds max delay: %w", se.RetryAfter, err)
+			}
+			c.limiter.Pause(se.RetryAfter)
+			delay = max(delay, se.RetryAfter)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package stox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IssueKind string
+
+const (
+	IssueUnknownField     IssueKind = "unknown_field"
+	IssueMissingField     IssueKind = "missing_field"
+	IssueWrongType        IssueKind = "wrong_type"
+	IssueInvalidTimestamp IssueKind = "invalid_timestamp"
+	IssueNegativePrice    IssueKind = "negative_price"
+	IssueDecode           IssueKind = "decode"
+)
+
+// SchemaIssue is a difference between an upstream payload and the shape we
+// decode it into. Issues on array elements share a path with [] in place of
+// the index and are counted rather than repeated.
+type SchemaIssue struct {
+	Kind   IssueKind `json:"kind"`
+	Path   string    `json:"path"`
+	Detail string    `json:"detail,omitempty"`
+	Count  int       `json:"count"`
+}
+
+func (i SchemaIssue) String() string {
+	s := string(i.Kind) + " " + i.Path
+	if i.Detail != "" {
+		s += ": " + i.Detail
+	}
+	if i.Count > 1 {
+		s += " (x" + strconv.Itoa(i.Count) + ")"
+	}
+	return s
+}
+
+// Drift is reported when a payload does not match the expected schema. The
+// raw payload is kept so the change can be inspected later.
+type Drift struct {
+	Endpoint  string
+	FetchedAt time.Time
+	Issues    []SchemaIssue
+	Payload   []byte
+}
+
+// Signature identifies the set of issues regardless of how often they occur,
+// so repeated drift of the same kind can be told apart from a new change. It
+// is empty when there are no issues.
+func (d Drift) Signature() string {
+	if len(d.Issues) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(d.Issues))
+	for _, i := range d.Issues {
+		keys = append(keys, string(i.Kind)+" "+i.Path)
+	}
+	slices.Sort(keys)
+	return d.Endpoint + "|" + strings.Join(keys, ",")
+}
+
+type issueSet map[string]*SchemaIssue
+
+func (s issueSet) add(kind IssueKind, path, detail string) {
+	key := string(kind) + " " + path
+	if i, ok := s[key]; ok {
+		i.Count++
+		return
+	}
+	s[key] = &SchemaIssue{Kind: kind, Path: path, Detail: detail, Count: 1}
+}
+
+func (s issueSet) list() []SchemaIssue {
+	issues := make([]SchemaIssue, 0, len(s))
+	for _, key := range slices.Sorted(maps.Keys(s)) {
+		issues = append(issues, *s[key])
+	}
+	return issues
+}
+
+// validateShape compares a payload against the type it is decoded into and
+// returns every unknown, missing or mistyped field.
+func validateShape(body []byte, t reflect.Type) ([]SchemaIssue, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	issues := issueSet{}
+	walkShape(issues, v, t, "$")
+	return issues.list(), nil
+}
+
+func walkShape(issues issueSet, v any, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Nulls decode into zero values, which is how the API marks absent
+	// optional data.
+	if v == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected object, got %s", jsonType(v)))
+			return
+		}
+
+		fields := jsonFields(t)
+		for key, val := range obj {
+			f, ok := fields[key]
+			if !ok {
+				issues.add(IssueUnknownField, path+"."+key, jsonType(val))
+				continue
+			}
+			walkShape(issues, val, f.Type, path+"."+key)
+		}
+
+		for name, f := range fields {
+			if _, ok := obj[name]; !ok && f.Type.Kind() != reflect.Pointer {
+				issues.add(IssueMissingField, path+"."+name, "")
+			}
+		}
+
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected object, got %s", jsonType(v)))
+			return
+		}
+		for _, val := range obj {
+			walkShape(issues, val, t.Elem(), path+".*")
+		}
+
+	case reflect.Slice:
+		arr, ok := v.([]any)
+		if !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected array, got %s", jsonType(v)))
+			return
+		}
+		for _, val := range arr {
+			walkShape(issues, val, t.Elem(), path+"[]")
+		}
+
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected string, got %s", jsonType(v)))
+		}
+
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected bool, got %s", jsonType(v)))
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(json.Number)
+		if !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected integer, got %s", jsonType(v)))
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			issues.add(IssueWrongType, path, "expected integer, got "+n.String())
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(json.Number); !ok {
+			issues.add(IssueWrongType, path, fmt.Sprintf("expected number, got %s", jsonType(v)))
+		}
+	}
+}
+
+// jsonFields maps the json names of a struct's exported fields to the fields.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// decode unmarshals body into v and returns every schema issue found in the
+// payload. A payload that cannot be decoded is returned as a decode issue along
+// with the error.
+func decode(body []byte, v any) ([]SchemaIssue, error) {
+	issues, err := validateShape(body, reflect.TypeOf(v))
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		issues = append(issues, SchemaIssue{Kind: IssueDecode, Path: "$", Detail: err.Error(), Count: 1})
+		return issues, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	return issues, nil
+}
+
+type pricePoint struct {
+	ticker    string
+	timestamp string
+}
+
+// validatePrices checks the values of a price payload, flagging timestamps
+// that are not integers and negative prices. It returns the points that should
+// be dropped from the decoded data.
+func validatePrices(data PriceData) ([]SchemaIssue, []pricePoint) {
+	issues := issueSet{}
+	var invalid []pricePoint
+
+	for ticker, series := range data.Prices {
+		for ts, price := range series {
+			if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+				issues.add(IssueInvalidTimestamp, "$.prices."+ticker, ts)
+				invalid = append(invalid, pricePoint{ticker, ts})
+				continue
+			}
+			if price < 0 {
+				issues.add(IssueNegativePrice, "$.prices."+ticker, ts+": "+strconv.Itoa(price))
+				invalid = append(invalid, pricePoint{ticker, ts})
+			}
+		}
+	}
+
+	return issues.list(), invalid
+}
@@ -8,7 +8,7 @@ import (
 
 func GenerateChartData(prices []models.StockPrice) string {
 	var timestamps []int64
-	var values []int
+	var values []models.Price
 
 	for _, price := range prices {
 		timestamps = append(timestamps, price.Timestamp)
@@ -16,8 +16,8 @@ func GenerateChartData(prices []models.StockPrice) string {
 	}
 
 	chartData := struct {
-		Timestamps []int64 `json:"timestamps"`
-		Values     []int   `json:"values"`
+		Timestamps []int64        `json:"timestamps"`
+		Values     []models.Price `json:"values"`
 	}{
 		Timestamps: timestamps,
 		Values:     values,
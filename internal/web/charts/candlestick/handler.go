@@ -7,18 +7,26 @@ import (
 
 	"github.com/JamesTiberiusKirk/fishstox/internal/components"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
 	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
 )
 
-func NewHandler(db *db.Client) http.Handler {
+// defaultCandles is roughly how many candles a chart shows when no resolution
+// is requested, and maxCandles the most a requested resolution may produce.
+const (
+	defaultCandles = 24
+	maxCandles     = 500
+)
+
+func NewHandler(db db.PriceStore) http.Handler {
 	return &handler{
 		db: db,
 	}
 }
 
 type handler struct {
-	db *db.Client
+	db db.PriceStore
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -40,31 +48,71 @@ func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	from := time.Now().Add(-24 * time.Hour)
-	to := time.Now()
-
-	rawPrices, err := h.db.GetStockPricesByTimeFrame(tickerQuery, from, to)
-	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
+	if _, ok, err := h.db.GetTicker(r.Context(), tickerQuery); err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting ticker", "ticker", tickerQuery, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		components.ServerError(r, err.Error()).Render(r.Context(), w)
 		return
+	} else if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		components.NotFound(r, "Ticker not found").Render(r.Context(), w)
+		return
+	}
+
+	timeRange, err := models.ParseTimeRangeQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	resolution := timeRange.Resolution(defaultCandles)
+	if raw := r.URL.Query().Get("resolution"); raw != "" {
+		if resolution, err = models.ParseResolution(raw); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			components.BadRequest(r, err.Error()).Render(r.Context(), w)
+			return
+		}
+
+		if timeRange.Buckets(resolution) > maxCandles {
+			w.WriteHeader(http.StatusBadRequest)
+			components.BadRequest(r, fmt.Sprintf("resolution %s is too fine for the range, at most %d candles are shown", resolution, maxCandles)).Render(r.Context(), w)
+			return
+		}
 	}
 
-	interval := 3600000 // 1 hour in milliseconds
-	candles, err := prices.CalculateCandlestick(rawPrices, interval)
+	align, err := prices.ParseAlignmentQuery(r.URL.Query(), resolution.Duration())
 	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error processing prices", "ticker", tickerQuery, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	buckets, err := h.db.GetPriceBuckets(r.Context(), tickerQuery, prices.Options{
+		Range: timeRange,
+		Width: resolution.Duration(),
+		Align: align,
+		// Flat candles keep the candles evenly spaced over quiet periods.
+		Empty: prices.EmptyCarryForward,
+	})
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		components.ServerError(r, err.Error()).Render(r.Context(), w)
 		return
 	}
 
+	candles := make([]models.Candle, 0, len(buckets))
+	for _, b := range buckets {
+		candles = append(candles, b.Candle())
+	}
+
 	fmt.Println(len(candles))
 
 	pageData := pageProps{
 		tickerQuery: tickerQuery,
 		candles:     candles,
+		interval:    int(resolution.Duration().Milliseconds()),
 	}
 
 	w.WriteHeader(http.StatusOK)
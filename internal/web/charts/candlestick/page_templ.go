@@ -8,6 +8,7 @@ import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
 import (
+	"github.com/JamesTiberiusKirk/fishstox/internal/components"
 	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 	"net/http"
 )
@@ -16,6 +17,8 @@ import (
 type pageProps struct {
 	tickerQuery string
 	candles     []models.Candle
+	// interval is the candle width in milliseconds.
+	interval int
 }
 
 // templ page renders the page template
@@ -40,11 +43,30 @@ func page(r *http.Request, props pageProps) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div style=\"width:1000px\"><canvas id=\"chart\"></canvas></div><script>\n\n    // var barCount = 60;\n    // var initialDateStr = new Date().toUTCString();\n\n\n    function initFinChart(canvasId, barData){\n\t// var barData = new Array(barCount);\n\tvar lineData = new Array(barData.lenght);\n\t// getRandomData(initialDateStr);\n\n\n\t// bar data\n\t// {\n\t//  x: date.valueOf(),\n\t//  o: open,\n\t//  h: high,\n\t//  l: low,\n\t//  c: close\n\t// }\n\n\tconsole.log(barData)\n\n\t// Convert raw timestamps (in milliseconds) to DateTime objects\n\tconst timestamps = barData.map(bd => {\n\t    const validTimestamp = Number(bd.x);\n\t    if (isNaN(validTimestamp)) {\n\t\tconsole.error(\"Invalid timestamp:\", bd.x);\n\t\treturn null;\n\t    }\n\t    const dt = luxon.DateTime.fromMillis(validTimestamp);\n\t    return dt.isValid ? dt.toMillis() : null;\n\t}).filter(ts => ts !== null);\n\n\tvar chart = new Chart(document.getElementById(canvasId), {\n\t    type: 'candlestick',\n\t    data: {\n\t\tdatasets: [{\n\t\t    label: 'CHRT - Chart.js Corporation',\n\t\t    data: barData,\n\t\t}, {\n\t\t\tlabel: 'Close price',\n\t\t\ttype: 'line',\n\t\t\tdata: timestamps,\n\t\t\thidden: true,\n\t\t    }]\n\t    },\n\t    options: {\n\t\tresponsive: true,\n\t\tscales: {\n\t\t    x: {\n\t\t\ttype: 'time',\n\t\t\ttime: {\n\t\t\t    unit: 'minute',\n\t\t\t    tooltipFormat: 'll HH:mm',\n\t\t\t    displayFormats: {\n\t\t\t\tminute: 'dd/MM HH:mm',\n\t\t\t\thour: 'dd/MM HH:mm',\n\t\t\t\tday: 'dd/MM',\n\t\t\t    }\n\t\t\t},\n\t\t\tticks: {\n\t\t\t    source: 'data',\n\t\t\t    callback: function(value, index, values) {\n\t\t\t\treturn value\n\t\t\t    }\n\t\t\t},\n\t\t    },\n\t\t    y: {\n\t\t\tbeginAtZero: false,\n\t\t    },\n\t\t},\n\t\tplugins: {\n\t\t    tooltip: {\n\t\t\tcallbacks: {\n\t\t\t    label: function(tooltipItem) {\n\t\t\t\tconst date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n\t\t\t\treturn date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${tooltipItem.raw.y}` : '';\n\t\t\t    }\n\t\t\t}\n\t\t    }\n\t\t}\n\t    }\n\t});\n    }\n\n    </script>")
+		if len(props.candles) > 0 {
+			templ_7745c5c3_Err = components.PriceSummary(props.candles[0].Open, props.candles[len(props.candles)-1].Close).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div style=\"width:1000px\"><canvas id=\"chart\"></canvas></div><div id=\"chart-live\" hx-ext=\"sse\" sse-connect=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs("/live/prices/" + props.tickerQuery)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/web/charts/candlestick/page.templ`, Line: 25, Col: 84}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" sse-swap=\"prices\" style=\"display: none;\"></div><script>\n\n    // var barCount = 60;\n    // var initialDateStr = new Date().toUTCString();\n\n\n    function initFinChart(canvasId, barData, liveId, interval){\n\t// var barData = new Array(barCount);\n\tvar lineData = new Array(barData.lenght);\n\t// getRandomData(initialDateStr);\n\n\n\t// bar data\n\t// {\n\t//  x: date.valueOf(),\n\t//  o: open,\n\t//  h: high,\n\t//  l: low,\n\t//  c: close\n\t// }\n\n\tconsole.log(barData)\n\n\t// Convert raw timestamps (in milliseconds) to DateTime objects\n\tconst timestamps = barData.map(bd => {\n\t    const validTimestamp = Number(bd.x);\n\t    if (isNaN(validTimestamp)) {\n\t\tconsole.error(\"Invalid timestamp:\", bd.x);\n\t\treturn null;\n\t    }\n\t    const dt = luxon.DateTime.fromMillis(validTimestamp);\n\t    return dt.isValid ? dt.toMillis() : null;\n\t}).filter(ts => ts !== null);\n\n\tvar chart = new Chart(document.getElementById(canvasId), {\n\t    type: 'candlestick',\n\t    data: {\n\t\tdatasets: [{\n\t\t    label: 'CHRT - Chart.js Corporation',\n\t\t    data: barData,\n\t\t}, {\n\t\t\tlabel: 'Close price',\n\t\t\ttype: 'line',\n\t\t\tdata: timestamps,\n\t\t\thidden: true,\n\t\t    }]\n\t    },\n\t    options: {\n\t\tresponsive: true,\n\t\tscales: {\n\t\t    x: {\n\t\t\ttype: 'time',\n\t\t\ttime: {\n\t\t\t    unit: 'minute',\n\t\t\t    tooltipFormat: 'll HH:mm',\n\t\t\t    displayFormats: {\n\t\t\t\tminute: 'dd/MM HH:mm',\n\t\t\t\thour: 'dd/MM HH:mm',\n\t\t\t\tday: 'dd/MM',\n\t\t\t    }\n\t\t\t},\n\t\t\tticks: {\n\t\t\t    source: 'data',\n\t\t\t    callback: function(value, index, values) {\n\t\t\t\treturn value\n\t\t\t    }\n\t\t\t},\n\t\t    },\n\t\t    y: {\n\t\t\tbeginAtZero: false,\n\t\t    },\n\t\t},\n\t\tplugins: {\n\t\t    tooltip: {\n\t\t\tcallbacks: {\n\t\t\t    label: function(tooltipItem) {\n\t\t\t\tconst date = luxon.DateTime.fromMillis(tooltipItem.raw.x);\n\t\t\t\treturn date.isValid ? `${date.toFormat('dd/MM HH:mm')}: ₣${Number(tooltipItem.raw.y).toLocaleString('en-US', { minimumFractionDigits: tooltipItem.raw.y % 1 ? 2 : 0, maximumFractionDigits: 2 })}` : '';\n\t\t\t    }\n\t\t\t}\n\t\t    }\n\t\t}\n\t    }\n\t});\n\n\t// Fold prices streamed by the live element into the last candle, or\n\t// start a new one once a price falls past its interval.\n\tdocument.getElementById(liveId).addEventListener('htmx:sseBeforeMessage', function(evt) {\n\t    evt.preventDefault();\n\t    JSON.parse(evt.detail.data).forEach(p => {\n\t\t// Candles are plotted at the mid-point of their interval.\n\t\tconst x = p.timestamp - (p.timestamp % interval) + interval / 2;\n\t\tconst last = barData[barData.length - 1];\n\t\tif (last && last.x === x) {\n\t\t    last.h = Math.max(last.h, p.value);\n\t\t    last.l = Math.min(last.l, p.value);\n\t\t    last.c = p.value;\n\t\t} else if (!last || last.x < x) {\n\t\t    barData.push({ticker: p.ticker, x: x, o: p.value, h: p.value, l: p.value, c: p.value});\n\t\t}\n\t    });\n\t    chart.update('none');\n\t});\n    }\n\n    </script>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templ.JSFuncCall("initFinChart", "chart", props.candles).Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = templ.JSFuncCall("initFinChart", "chart", props.candles, "chart-live", props.interval).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
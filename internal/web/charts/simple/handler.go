@@ -7,18 +7,19 @@ import (
 
 	"github.com/JamesTiberiusKirk/fishstox/internal/components"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
 	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
 )
 
-func NewHandler(db *db.Client) http.Handler {
+func NewHandler(db db.PriceStore) http.Handler {
 	return &handler{
 		db: db,
 	}
 }
 
 type handler struct {
-	db *db.Client
+	db db.PriceStore
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -33,7 +34,11 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handler) get(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(5 * time.Second)
+	select {
+	case <-r.Context().Done():
+		return
+	case <-time.After(5 * time.Second):
+	}
 
 	tickerQuery := r.PathValue("tickerQuery")
 	if tickerQuery == "" {
@@ -42,15 +47,22 @@ func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	from := time.Now().Add(-24 * time.Hour)
-	to := time.Now()
-
-	rawPrices, err := h.db.GetStockPricesByTimeFrame(tickerQuery, from, to)
-	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
+	if _, ok, err := h.db.GetTicker(r.Context(), tickerQuery); err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting ticker", "ticker", tickerQuery, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		components.ServerError(r, err.Error()).Render(r.Context(), w)
 		return
+	} else if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		components.NotFound(r, "Ticker not found").Render(r.Context(), w)
+		return
+	}
+
+	timeRange, err := models.ParseTimeRangeQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
+		return
 	}
 
 	amountOfPricesRaw := r.URL.Query().Get("amountOfPrices")
@@ -67,14 +79,38 @@ func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	p, err := prices.ConcatAndAverage(rawPrices, amountOfPrices, from, to)
+	if amountOfPrices <= 0 {
+		slogctx.Ctx(r.Context()).Error("Invalid amount of prices", "amountOfPrices", amountOfPrices)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, "amount of prices must be greater than 0").Render(r.Context(), w)
+		return
+	}
+
+	resolution := timeRange.Resolution(amountOfPrices)
+	align, err := prices.ParseAlignmentQuery(r.URL.Query(), resolution.Duration())
 	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error processing prices", "ticker", tickerQuery, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	buckets, err := h.db.GetPriceBuckets(r.Context(), tickerQuery, prices.Options{
+		Range: timeRange,
+		Width: resolution.Duration(),
+		Align: align,
+	})
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		components.ServerError(r, err.Error()).Render(r.Context(), w)
 		return
 	}
 
+	p := make([]models.StockPrice, 0, len(buckets))
+	for _, b := range buckets {
+		p = append(p, b.Average())
+	}
+
 	pageData := pageProps{
 		tickerQuery: tickerQuery,
 		prices:      p,
@@ -41,7 +41,13 @@ func page(r *http.Request, props pageProps) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = components.SimpleGraph(components.SimpleGraphProps{ID: props.tickerQuery, Prices: props.prices, TickerQuery: props.tickerQuery}).Render(ctx, templ_7745c5c3_Buffer)
+		if len(props.prices) > 0 {
+			templ_7745c5c3_Err = components.PriceSummary(props.prices[0].Value, props.prices[len(props.prices)-1].Value).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = components.SimpleGraph(components.SimpleGraphProps{ID: props.tickerQuery, Prices: props.prices, TickerQuery: props.tickerQuery, LiveURL: "/live/prices/" + props.tickerQuery}).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -7,18 +7,19 @@ import (
 
 	"github.com/JamesTiberiusKirk/fishstox/internal/components"
 	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/models"
 	"github.com/JamesTiberiusKirk/fishstox/internal/prices"
 	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
 )
 
-func NewHandler(db *db.Client) http.Handler {
+func NewHandler(db db.PriceStore) http.Handler {
 	return &handler{
 		db: db,
 	}
 }
 
 type handler struct {
-	db *db.Client
+	db db.PriceStore
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -35,16 +36,38 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 	tickerQuery := r.URL.Query().Get("tickerQuery")
 	if tickerQuery == "" {
-		tickerQuery = "DRNC"
+		tickers, err := h.db.ListTickers(r.Context(), false)
+		if err != nil {
+			slogctx.Ctx(r.Context()).Error("Error listing tickers", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			components.ServerError(r, err.Error()).Render(r.Context(), w)
+			return
+		}
+
+		if len(tickers) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			components.NotFound(r, "No tickers yet").Render(r.Context(), w)
+			return
+		}
+
+		tickerQuery = tickers[0].Ticker
 	}
 
-	from := time.Now().Add(-24 * time.Hour)
-	to := time.Now()
+	if _, ok, err := h.db.GetTicker(r.Context(), tickerQuery); err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting ticker", "ticker", tickerQuery, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		return
+	} else if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		components.NotFound(r, "Ticker not found").Render(r.Context(), w)
+		return
+	}
 
-	rawPrices, err := h.db.GetStockPricesByTimeFrame(tickerQuery, from, to)
+	timeRange, err := models.ParseTimeRangeQuery(r.URL.Query(), time.Now())
 	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
-		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
 		return
 	}
 
@@ -56,23 +79,51 @@ func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 		amountOfPrices, err = strconv.Atoi(amountOfPricesRaw)
 		if err != nil {
 			slogctx.Ctx(r.Context()).Error("Error converting amount of prices", "amountOfPricesRaw", amountOfPricesRaw, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
 			components.ServerError(r, err.Error()).Render(r.Context(), w)
 			return
 		}
 	}
 
-	prices, err := prices.ConcatAndAverage(rawPrices, amountOfPrices, from, to)
+	if amountOfPrices <= 0 {
+		slogctx.Ctx(r.Context()).Error("Invalid amount of prices", "amountOfPrices", amountOfPrices)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, "amount of prices must be greater than 0").Render(r.Context(), w)
+		return
+	}
+
+	resolution := timeRange.Resolution(amountOfPrices)
+	align, err := prices.ParseAlignmentQuery(r.URL.Query(), resolution.Duration())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		components.BadRequest(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	buckets, err := h.db.GetPriceBuckets(r.Context(), tickerQuery, prices.Options{
+		Range: timeRange,
+		Width: resolution.Duration(),
+		Align: align,
+	})
 	if err != nil {
-		slogctx.Ctx(r.Context()).Error("Error processing prices", "ticker", tickerQuery, "error", err)
+		slogctx.Ctx(r.Context()).Error("Error getting prices", "ticker", tickerQuery, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		components.ServerError(r, err.Error()).Render(r.Context(), w)
 		return
 	}
 
+	prices := make([]models.StockPrice, 0, len(buckets))
+	for _, b := range buckets {
+		prices = append(prices, b.Average())
+	}
+
 	pageData := pageProps{
 		tickerQuery:    tickerQuery,
 		amountOfPrices: amountOfPrices,
-		from:           from,
-		to:             to,
+		rangeQuery:     r.URL.Query().Get("range"),
+		tz:             r.URL.Query().Get("tz"),
+		timeRange:      timeRange,
+		resolution:     resolution,
 		prices:         prices,
 	}
 
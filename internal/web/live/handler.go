@@ -0,0 +1,102 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/live"
+	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
+)
+
+// keepAliveInterval is how often a comment is sent on idle streams so proxies
+// do not close them.
+const keepAliveInterval = 30 * time.Second
+
+// PricesEvent is the SSE event name new prices are sent as.
+const PricesEvent = "prices"
+
+func NewHandler(hub *live.Hub, db db.PriceStore) http.Handler {
+	return &handler{
+		hub: hub,
+		db:  db,
+	}
+}
+
+type handler struct {
+	hub *live.Hub
+	db  db.PriceStore
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.get(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// get streams new prices of a ticker as server sent events, each event holding
+// a JSON array of prices.
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	tickerQuery := r.PathValue("tickerQuery")
+	if tickerQuery == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if _, ok, err := h.db.GetTicker(r.Context(), tickerQuery); err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting ticker", "ticker", tickerQuery, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	prices, unsubscribe := h.hub.Subscribe(tickerQuery)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case p := <-prices:
+			data, err := json.Marshal(p)
+			if err != nil {
+				slogctx.Ctx(r.Context()).Error("Error encoding prices", "ticker", tickerQuery, "error", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", PricesEvent, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package scrapes
+
+import (
+	"net/http"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/components"
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
+)
+
+const (
+	recentRunsLimit  = 100
+	recentDriftLimit = 20
+)
+
+func NewHandler(db db.ScrapeStore) http.Handler {
+	return &handler{
+		db: db,
+	}
+}
+
+type handler struct {
+	db db.ScrapeStore
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.get(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.db.GetRecentScrapeRuns(r.Context(), recentRunsLimit)
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting scrape runs", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	streaks, err := h.db.GetScrapeFailureStreaks(r.Context())
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting scrape failure streaks", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	drift, err := h.db.GetRecentDriftEvents(r.Context(), recentDriftLimit)
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error getting drift events", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	pageData := pageProps{
+		runs:    runs,
+		streaks: streaks,
+		drift:   drift,
+	}
+
+	page(r, pageData).Render(r.Context(), w)
+}
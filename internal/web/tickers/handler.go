@@ -0,0 +1,47 @@
+package tickers
+
+import (
+	"net/http"
+
+	"github.com/JamesTiberiusKirk/fishstox/internal/components"
+	"github.com/JamesTiberiusKirk/fishstox/internal/db"
+	"github.com/JamesTiberiusKirk/fishstox/internal/slogctx"
+)
+
+const searchLimit = 10
+
+func NewHandler(db db.PriceStore) http.Handler {
+	return &handler{
+		db: db,
+	}
+}
+
+type handler struct {
+	db db.PriceStore
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.get(w, r)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// get renders the tickers matching the tickerQuery param as datalist options
+// for autocomplete.
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	tickers, err := h.db.SearchTickers(r.Context(), r.URL.Query().Get("tickerQuery"), searchLimit)
+	if err != nil {
+		slogctx.Ctx(r.Context()).Error("Error searching tickers", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		components.ServerError(r, err.Error()).Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	options(tickers).Render(r.Context(), w)
+}